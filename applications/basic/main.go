@@ -1,15 +1,137 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/startup"
 )
 
+// started is initialized as early as possible when the process starts.
+var started = time.Now()
+
 func main() {
 	log.Println("Starting test app")
 
-	http.ListenAndServe(":8080", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	// The name of the pod allows podspeed to identify which pod served a
+	// request. The hostname is only a fallback as it might be truncated.
+	podName := os.Getenv(startup.PodNameEnv)
+	if podName == "" {
+		podName, _ = os.Hostname()
+	}
+
+	if err := initialize(); err != nil {
+		log.Fatalln("Failed to initialize", err)
+	}
+
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		log.Fatalln("Failed to listen", err)
+	}
+
+	var (
+		mu         sync.Mutex
+		timestamps = startup.Timestamps{
+			ProcessStarted: started,
+			ListenerBound:  time.Now(),
+		}
+	)
+
+	if url := os.Getenv(startup.CallbackEnv); url != "" {
+		go report(url, startup.Report{Pod: podName, Timestamps: timestamps})
+	}
+
+	http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(startup.PodNameHeader, podName)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == startup.Path {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(timestamps)
+			return
+		}
+		if timestamps.FirstRequest.IsZero() {
+			timestamps.FirstRequest = time.Now()
+		}
 		fmt.Fprintln(w, "success")
 	}))
 }
+
+// report sends the given report to podspeed, retrying a few times on errors.
+func report(url string, r startup.Report) {
+	body, err := json.Marshal(r)
+	if err != nil {
+		log.Println("Failed to encode report", err)
+		return
+	}
+	for i := 0; i < 10; i++ {
+		resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 300 {
+				return
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	log.Println("Failed to report to", url)
+}
+
+// initialize simulates the initialization of an application as configured
+// via the environment:
+//
+//   - INIT_DELAY: a duration to sleep for
+//   - INIT_CPU_BURN: a duration to keep a CPU busy for
+//   - INIT_ALLOC_MB: the amount of memory to allocate and touch in megabytes
+func initialize() error {
+	if v := os.Getenv("INIT_DELAY"); v != "" {
+		delay, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("failed to parse INIT_DELAY: %w", err)
+		}
+		time.Sleep(delay)
+	}
+
+	if v := os.Getenv("INIT_CPU_BURN"); v != "" {
+		burn, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("failed to parse INIT_CPU_BURN: %w", err)
+		}
+		var x uint64
+		for deadline := time.Now().Add(burn); time.Now().Before(deadline); {
+			for i := 0; i < 1000; i++ {
+				x = x*31 + uint64(i)
+			}
+		}
+		_ = x
+	}
+
+	if v := os.Getenv("INIT_ALLOC_MB"); v != "" {
+		mb, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("failed to parse INIT_ALLOC_MB: %w", err)
+		}
+		allocated = make([]byte, mb<<20)
+		// Touch every page to actually have the memory allocated.
+		for i := 0; i < len(allocated); i += 4096 {
+			allocated[i] = 1
+		}
+	}
+	return nil
+}
+
+// allocated keeps the memory allocated at startup alive.
+var allocated []byte
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/markusthoemmes/podspeed/pkg/benchmark"
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+	"github.com/markusthoemmes/podspeed/pkg/record"
+)
+
+// commandAnalyze is the first argument that analyzes a recording.
+const commandAnalyze = "analyze"
+
+// recordingHeader describes the recorded run.
+type recordingHeader struct {
+	runInfo
+	Metadata *runMetadata `json:"metadata,omitempty"`
+}
+
+// runAnalyze rebuilds the results of a run recorded via -record and produces
+// the same outputs as the run itself, without access to a cluster.
+func runAnalyze(args []string) {
+	out := newOutputs()
+
+	flags := newFlagSet(commandAnalyze)
+	out.register(flags)
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		log.Fatalln("Failed to open recording", err)
+	}
+	defer file.Close()
+
+	var (
+		header  recordingHeader
+		result  benchmark.Result
+		tracker = pod.NewTracker()
+	)
+	if err := record.Replay(file, tracker, &header, &result); err != nil {
+		log.Fatalln("Failed to replay recording", err)
+	}
+	result.Stats = tracker.Stats()
+	info := header.runInfo
+	out.metadata = header.Metadata
+	if _, ok := out.push.labels["template"]; !ok {
+		out.push.labels["template"] = info.Type
+		if out.metadata != nil && out.metadata.Template.Name != "" {
+			out.push.labels["template"] = out.metadata.Template.Name
+		}
+	}
+
+	printResult(info, &result, out.details)
+	if err := out.export(context.Background(), info, &result); err != nil {
+		log.Fatalln("Failed to export results", err)
+	}
+}
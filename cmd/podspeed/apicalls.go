@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/apicalls"
+	statistics "github.com/montanaflynn/stats"
+)
+
+// printCalls prints the latencies of all API calls podspeed made and how long
+// they were throttled by the client itself. The kubeContext is only set if
+// several clusters are compared.
+func printCalls(recorder *apicalls.Recorder, kubeContext string) {
+	fmt.Println()
+	fmt.Printf("API calls made by podspeed%s, latencies are in ms:\n", against(kubeContext))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
+	fmt.Fprintln(w, "call\tcount\tstatuses\tmin\tmax\tmedian\tp95")
+	for _, c := range recorder.Calls() {
+		data := make([]float64, 0, len(c.Latencies))
+		for _, l := range c.Latencies {
+			data = append(data, float64(l)/float64(time.Millisecond))
+		}
+		min, _ := statistics.Min(data)
+		max, _ := statistics.Max(data)
+		median, _ := statistics.Median(data)
+		p95, _ := statistics.Percentile(data, 95)
+		fmt.Fprintf(w, "%s %s\t%d\t%s\t%.0f\t%.0f\t%.0f\t%.0f\n", c.Verb, c.Resource, len(c.Latencies), formatStatuses(c.Statuses), min, max, median, p95)
+	}
+	w.Flush()
+
+	t := recorder.Throttling()
+	fmt.Println()
+	fmt.Printf("%d of %d requests waited for the client-side rate limiter (-qps %g, -burst %d), %d ms in total and up to %d ms each\n",
+		t.Throttled, t.Requests, t.QPS, t.Burst, t.Waited/time.Millisecond, t.MaxWait/time.Millisecond)
+	if t.Throttled > 0 {
+		fmt.Println("Throttled requests delay podspeed itself and distort the results, consider raising -qps and -burst")
+	}
+}
+
+// formatStatuses formats the counts of statuses, i.e. "201 x20, 409 x1".
+func formatStatuses(statuses map[string]int) string {
+	formatted := make([]string, 0, len(statuses))
+	for status, n := range statuses {
+		formatted = append(formatted, fmt.Sprintf("%s x%d", status, n))
+	}
+	sort.Strings(formatted)
+	return strings.Join(formatted, ", ")
+}
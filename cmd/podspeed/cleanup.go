@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/markusthoemmes/podspeed/pkg/knative"
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// commandCleanup deletes all objects left behind by podspeed.
+const commandCleanup = "cleanup"
+
+// cleanupResources are all resources podspeed creates, in the order they're
+// deleted. Owners come first to not have their controllers recreate what was
+// just deleted. Cluster-scoped resources are only deleted along with all
+// namespaces.
+var cleanupResources = []struct {
+	resource schema.GroupVersionResource
+	selector string
+	cluster  bool
+}{
+	{resource: knative.ServiceResource, selector: pod.RunLabel},
+	{resource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "daemonsets"}, selector: warmupLabel},
+	{resource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, selector: pod.RunLabel},
+	{resource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}, selector: pod.RunLabel},
+	{resource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "replicasets"}, selector: pod.RunLabel},
+	{resource: schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}, selector: pod.RunLabel},
+	{resource: schema.GroupVersionResource{Version: "v1", Resource: "pods"}, selector: pod.RunLabel},
+	{resource: schema.GroupVersionResource{Version: "v1", Resource: "services"}, selector: pod.RunLabel},
+	{resource: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, selector: pod.RunLabel},
+	{resource: schema.GroupVersionResource{Version: "v1", Resource: "secrets"}, selector: pod.RunLabel},
+	{resource: schema.GroupVersionResource{Version: "v1", Resource: "persistentvolumeclaims"}, selector: pod.RunLabel},
+	{resource: schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, selector: pod.RunLabel, cluster: true},
+}
+
+func runCleanup(args []string) {
+	var (
+		ns            string
+		allNamespaces bool
+		dryRun        bool
+		cluster       kubeFlags
+	)
+
+	flags := newFlagSet(commandCleanup)
+	flags.StringVar(&ns, "n", "default", "the namespace to delete the objects from")
+	flags.BoolVar(&allNamespaces, "A", false, "delete the objects from all namespaces, as well as the namespaces created via -create-namespace")
+	flags.BoolVar(&dryRun, "dry-run", false, "only print the objects that would be deleted")
+	cluster.register(flags)
+	flags.Parse(args)
+
+	if allNamespaces {
+		ns = metav1.NamespaceAll
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	config, _ := cluster.client()
+	dyn, err := dynamic.NewForConfig(config)
+	if err != nil {
+		log.Fatalln("Failed to create dynamic Kubernetes client", err)
+	}
+
+	var deleted int
+	for _, r := range cleanupResources {
+		if r.cluster && !allNamespaces {
+			continue
+		}
+		n, err := cleanupResource(ctx, dyn, r.resource, ns, r.selector, dryRun)
+		if err != nil {
+			log.Fatalln("Failed to clean up", err)
+		}
+		deleted += n
+	}
+	if dryRun {
+		fmt.Printf("Would delete %d objects\n", deleted)
+	} else {
+		fmt.Printf("Deleted %d objects\n", deleted)
+	}
+}
+
+// cleanupResource deletes all objects of the given resource that match the
+// selector and returns how many there were. Resources that don't exist in the
+// cluster, like Knative Services, are skipped.
+func cleanupResource(ctx context.Context, dyn dynamic.Interface, resource schema.GroupVersionResource, ns, selector string, dryRun bool) (int, error) {
+	list, err := dyn.Resource(resource).Namespace(ns).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if apierrors.IsNotFound(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to list %s: %w", resource.Resource, err)
+	}
+
+	propagation := metav1.DeletePropagationBackground
+	for _, obj := range list.Items {
+		if obj.GetNamespace() != "" {
+			fmt.Printf("%s/%s in %s\n", resource.Resource, obj.GetName(), obj.GetNamespace())
+		} else {
+			fmt.Printf("%s/%s\n", resource.Resource, obj.GetName())
+		}
+		if dryRun {
+			continue
+		}
+		err := dyn.Resource(resource).Namespace(obj.GetNamespace()).Delete(ctx, obj.GetName(), metav1.DeleteOptions{
+			PropagationPolicy: &propagation,
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return 0, fmt.Errorf("failed to delete %s %q: %w", resource.Resource, obj.GetName(), err)
+		}
+	}
+	return len(list.Items), nil
+}
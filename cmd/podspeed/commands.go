@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// command is a subcommand of podspeed.
+type command struct {
+	name string
+	// args describes the arguments of the command in its usage.
+	args        string
+	description string
+	run         func(args []string)
+}
+
+func commands() []command {
+	return []command{
+		{name: commandRun, args: "[flags] [SCENARIO]", description: "Create pods, or run the steps of a scenario file, and measure how long they take to start.", run: runBenchmark},
+		{name: commandPrepull, args: "[flags]", description: "Pull the images of the pods to all nodes.", run: runPrepull},
+		{name: commandCleanup, args: "[flags]", description: "Delete all objects left behind by podspeed, i.e. by runs with -skip-delete or interrupted runs.", run: runCleanup},
+		{name: commandTypes, args: "list | show TYPE", description: "List the built-in pod types or show the pod of one of them.", run: runTypes},
+		{name: commandCompare, args: "[flags] BASE NEW", description: "Compare the results of two runs written via -results.", run: runCompare},
+		{name: commandAnalyze, args: "[flags] RECORDING", description: "Rebuild the results of a run recorded via -record, without access to a cluster.", run: runAnalyze},
+		{name: commandPreflight, args: "[flags]", description: "Check whether the cluster is set up to run podspeed.", run: runPreflight},
+		{name: commandMonitor, args: "[flags]", description: "Continuously measure the startup of pods and serve the results as Prometheus metrics.", run: runMonitor},
+		{name: commandVersion, args: "", description: "Print the version of podspeed.", run: runVersion},
+	}
+}
+
+// runCommand runs the command named by the first argument. No arguments or
+// arguments that start with flags run a benchmark, as podspeed did before it
+// had commands.
+func runCommand(args []string) {
+	if len(args) == 0 {
+		runBenchmark(args)
+		return
+	}
+	switch args[0] {
+	case "-h", "-help", "--help":
+		usage(os.Stdout)
+		return
+	case "help":
+		if len(args) > 1 {
+			runCommand([]string{args[1], "-h"})
+			return
+		}
+		usage(os.Stdout)
+		return
+	}
+	if strings.HasPrefix(args[0], "-") {
+		runBenchmark(args)
+		return
+	}
+
+	for _, c := range commands() {
+		if c.name == args[0] {
+			c.run(args[1:])
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+	usage(os.Stderr)
+	os.Exit(2)
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "podspeed benchmarks the startup of pods on Kubernetes clusters.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Usage: podspeed COMMAND [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	for _, c := range commands() {
+		fmt.Fprintf(tw, "  %s\t%s\n", c.name, c.description)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'podspeed COMMAND -h' for the flags of a command.")
+}
+
+// newFlagSet returns the flag set of the given command, printing its
+// description and flags as help.
+func newFlagSet(name string) *flag.FlagSet {
+	var cmd command
+	for _, c := range commands() {
+		if c.name == name {
+			cmd = c
+		}
+	}
+
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		w := flags.Output()
+		fmt.Fprintf(w, "Usage: podspeed %s %s\n\n", cmd.name, cmd.args)
+		fmt.Fprintln(w, cmd.description)
+		hasFlags := false
+		flags.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "Flags:")
+			flags.PrintDefaults()
+		}
+	}
+	return flags
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+)
+
+// commandCompare compares the results of two runs.
+const commandCompare = "compare"
+
+func runCompare(args []string) {
+	var threshold float64
+
+	flags := newFlagSet(commandCompare)
+	flags.Float64Var(&threshold, "threshold", 0, "exit with 1 if the median or p95 of any metric got slower by more than the given percentage, i.e. '10'")
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	base, err := readResults(flags.Arg(0))
+	if err != nil {
+		log.Fatalln("Failed to read base results", err)
+	}
+	current, err := readResults(flags.Arg(1))
+	if err != nil {
+		log.Fatalln("Failed to read new results", err)
+	}
+
+	runs := make(map[string]resultsRun, len(current.Runs))
+	for _, run := range current.Runs {
+		runs[run.name()] = run
+	}
+
+	fmt.Printf("Comparing %s to %s, results are in ms:\n", flags.Arg(1), flags.Arg(0))
+	regressed := false
+	for _, b := range base.Runs {
+		c, ok := runs[b.name()]
+		if !ok {
+			fmt.Println()
+			fmt.Printf("Run %q is missing in %s\n", b.name(), flags.Arg(1))
+			continue
+		}
+		delete(runs, b.name())
+
+		fmt.Println()
+		if b.name() != "" {
+			fmt.Printf("%s:\n", b.name())
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
+		fmt.Fprintln(w, "metric\tbase median\tnew median\tchange\tbase p95\tnew p95\tchange")
+		for _, bm := range b.Metrics {
+			cm, ok := findMetric(c.Metrics, bm.Name)
+			if !ok {
+				continue
+			}
+			medianChange := change(bm.Median, cm.Median)
+			p95Change := change(bm.P95, cm.P95)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", bm.Label,
+				formatStat(bm.Median), formatStat(cm.Median), formatChange(medianChange),
+				formatStat(bm.P95), formatStat(cm.P95), formatChange(p95Change))
+			if threshold > 0 && (exceeds(medianChange, threshold) || exceeds(p95Change, threshold)) {
+				regressed = true
+			}
+		}
+		w.Flush()
+	}
+	for _, run := range current.Runs {
+		if _, ok := runs[run.name()]; ok {
+			fmt.Println()
+			fmt.Printf("Run %q is missing in %s\n", run.name(), flags.Arg(0))
+		}
+	}
+
+	if regressed {
+		fmt.Println()
+		fmt.Printf("Some metrics got slower by more than %g%%\n", threshold)
+		os.Exit(1)
+	}
+}
+
+func findMetric(metrics []resultsMetric, name string) (resultsMetric, bool) {
+	for _, m := range metrics {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return resultsMetric{}, false
+}
+
+// change returns the relative change from base to current in percent, or nil
+// if either is unknown.
+func change(base, current *float64) *float64 {
+	if base == nil || current == nil || *base == 0 {
+		return nil
+	}
+	v := (*current - *base) / *base * 100
+	return &v
+}
+
+func exceeds(change *float64, threshold float64) bool {
+	return change != nil && *change > threshold
+}
+
+func formatStat(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f", *v)
+}
+
+func formatChange(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%+.1f%%", *v)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/knative"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// workloadKnative creates Knative Services rather than pods.
+const workloadKnative = "knative"
+
+// knativeMetric is a single metric reported in the summary table of Knative
+// Services.
+type knativeMetric struct {
+	label string
+	fn    func(knative.Stats) time.Duration
+}
+
+// runKnative benchmarks the startup of Knative Services and prints the results.
+func runKnative(ctx context.Context, config *rest.Config, kube kubernetes.Interface, opts knative.Options) error {
+	dyn, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to create dynamic Kubernetes client: %w", err)
+	}
+
+	stats, err := knative.Run(ctx, kube, dyn, http.DefaultClient, opts)
+	if err != nil {
+		return err
+	}
+
+	metrics := []knativeMetric{
+		{label: "Time to revision ready", fn: knative.Stats.TimeToRevisionReady},
+		{label: "Time to pod ready", fn: knative.Stats.TimeToPodReady},
+		{label: "Time to first request", fn: knative.Stats.TimeToFirstRequest},
+	}
+	if opts.ScaleFromZero {
+		metrics = append(metrics, knativeMetric{label: "Cold start from zero", fn: knative.Stats.ColdStart})
+	}
+
+	fmt.Printf("Created %d Knative Services sequentially, results are in ms:\n", opts.Services)
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
+	fmt.Fprintln(w, "metric\tmin\tmax\tmean\tmedian\tp25\tp75\tp95\tp99")
+	for _, m := range metrics {
+		data := make([]float64, 0, len(stats))
+		for _, stat := range stats {
+			data = append(data, float64(m.fn(*stat)/time.Millisecond))
+		}
+		printStats(w, m.label, data)
+	}
+	w.Flush()
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/markusthoemmes/podspeed/pkg/apicalls"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// kubeFlags are the flags shared by all commands that talk to a cluster.
+type kubeFlags struct {
+	kubeconfig string
+	context    string
+	as         string
+	qps        float64
+	burst      int
+}
+
+func (k *kubeFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&k.kubeconfig, "kubeconfig", "", "the kubeconfig file to use, defaults to $KUBECONFIG or ~/.kube/config and the in-cluster config if neither exists")
+	flags.StringVar(&k.context, "context", "", "the kubeconfig context to use, defaults to the current context")
+	flags.StringVar(&k.as, "as", "", "the user to impersonate")
+	flags.Float64Var(&k.qps, "qps", float64(rest.DefaultQPS), "the maximum requests per second to the API server before the client throttles itself, after an initial -burst")
+	flags.IntVar(&k.burst, "burst", rest.DefaultBurst, "the maximum burst of requests to the API server before the client throttles itself")
+}
+
+// config loads the Kubernetes config selected by the flags.
+func (k kubeFlags) config() (*rest.Config, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = k.kubeconfig
+	overrides := &clientcmd.ConfigOverrides{
+		CurrentContext: k.context,
+		AuthInfo: clientcmdapi.AuthInfo{
+			Impersonate: k.as,
+		},
+	}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.QPS = float32(k.qps)
+	config.Burst = k.burst
+	return config, nil
+}
+
+// client creates a client for the Kubernetes config selected by the flags, or
+// exits if that fails.
+func (k kubeFlags) client() (*rest.Config, *kubernetes.Clientset) {
+	return k.newClient(nil)
+}
+
+// recordedClient is like client, but records all API calls made through the
+// returned config.
+func (k kubeFlags) recordedClient() (*rest.Config, *kubernetes.Clientset, *apicalls.Recorder) {
+	recorder := apicalls.NewRecorder()
+	config, kube := k.newClient(recorder)
+	return config, kube, recorder
+}
+
+func (k kubeFlags) newClient(recorder *apicalls.Recorder) (*rest.Config, *kubernetes.Clientset) {
+	config, err := k.config()
+	if err != nil {
+		log.Fatalln("Failed to load config", err)
+	}
+	if recorder != nil {
+		recorder.Instrument(config)
+	}
+	kube, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		log.Fatalln("Failed to create Kubernetes client", err)
+	}
+	return config, kube
+}
@@ -1,48 +1,89 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
-	"net/http"
+	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"text/tabwriter"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/markusthoemmes/podspeed/pkg/benchmark"
+	"github.com/markusthoemmes/podspeed/pkg/callback"
+	"github.com/markusthoemmes/podspeed/pkg/knative"
+	"github.com/markusthoemmes/podspeed/pkg/metrics"
+	"github.com/markusthoemmes/podspeed/pkg/namespace"
 	"github.com/markusthoemmes/podspeed/pkg/pod"
+	"github.com/markusthoemmes/podspeed/pkg/pod/matrix"
 	podtemplate "github.com/markusthoemmes/podspeed/pkg/pod/template"
 	podtypes "github.com/markusthoemmes/podspeed/pkg/pod/types"
+	"github.com/markusthoemmes/podspeed/pkg/preflight"
+	"github.com/markusthoemmes/podspeed/pkg/progress"
+	"github.com/markusthoemmes/podspeed/pkg/record"
 	statistics "github.com/montanaflynn/stats"
-	appsv1 "k8s.io/api/apps/v1"
-	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/util/wait"
-	"k8s.io/apimachinery/pkg/watch"
-	"k8s.io/client-go/kubernetes"
-	clientappsv1 "k8s.io/client-go/kubernetes/typed/apps/v1"
-	"k8s.io/client-go/tools/clientcmd"
+	"golang.org/x/term"
+	"k8s.io/apimachinery/pkg/runtime"
 
 	// Allow podspeed to run against a GCP cluster
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 )
 
 func main() {
+	runCommand(os.Args[1:])
+}
+
+// commandRun runs a benchmark, configured via flags or a scenario file.
+const commandRun = "run"
+
+// runBenchmark creates pods and measures their startup, as configured by the
+// given flags or the scenario file given as argument.
+func runBenchmark(args []string) {
 	var (
 		ns         string
 		typ        string
 		template   string
 		podN       int
 		skipDelete bool
+		dryRun     bool
 		prepull    bool
 		probe      bool
-		details    bool
+		endpoints  bool
+		probeSvc   string
+		timestamps bool
+		cbAddr     string
+		cbURL      string
+		progress   bool
+		axes       axesFlag
+		out        = newOutputs()
+		recordFile string
+		workload   benchmark.Workload
+		scale      string
+		scaleFrom  int
+		scaleTo    int
+
+		knativeIngress       string
+		knativeScaleFromZero bool
+
+		cluster         kubeFlags
+		contexts        string
+		parallel        bool
+		preflightChecks bool
+
+		createNamespace   bool
+		namespaceN        int
+		namespaceSelector string
+		namespaceLabels   = metrics.Labels{}
 	)
 
 	supportedTypes, err := podtypes.Names()
@@ -50,229 +91,602 @@ func main() {
 		log.Fatalln("failed to built in types: ", err)
 	}
 
-	flag.StringVar(&ns, "n", "default", "the namespace to create the pods in")
-	flag.StringVar(&typ, "typ", "basic", "the type of pods to create, supported values: "+strings.Join(supportedTypes, ", "))
-	flag.StringVar(&template, "template", "", "a YAML template to create pods from, can be exported from Kubernetes directly via 'kubectl get pods -oyaml', reads stdin if '-'")
-	flag.IntVar(&podN, "pods", 1, "the amount of pods to create")
-	flag.BoolVar(&skipDelete, "skip-delete", false, "skip removing the pods after they're ready if true")
-	flag.BoolVar(&prepull, "prepull", false, "prepull all used images to all Kubernetes nodes")
-	flag.BoolVar(&probe, "probe", false, "probe the pods as soon as they have an IP address and capture latency of that as well")
-	flag.BoolVar(&details, "details", false, "print detailed timing information for each pod")
-	flag.Parse()
+	flags := newFlagSet(commandRun)
+	flags.StringVar(&ns, "n", "default", "the namespace to create the pods in")
+	flags.BoolVar(&createNamespace, "create-namespace", false, "create a fresh namespace named 'podspeed-<uuid>' for the run instead of using -n, and delete it afterwards, waiting for it to terminate")
+	flags.IntVar(&namespaceN, "namespaces", 0, "create the given amount of fresh namespaces named 'podspeed-<uuid>' and spread the pods across them one after the other instead of using -n, deleting them afterwards")
+	flags.StringVar(&namespaceSelector, "namespace-selector", "", "spread the pods across all existing namespaces matching the given label selector one after the other instead of using -n, i.e. 'team=perf'")
+	flags.Var(labelsFlag(namespaceLabels), "namespace-label", "a label in the form of 'name=value' to set on the namespaces created via -create-namespace or -namespaces, i.e. 'pod-security.kubernetes.io/enforce=restricted' or 'istio-injection=enabled', can be repeated")
+	flags.StringVar(&typ, "typ", "basic", "the type of pods to create, supported values: "+strings.Join(supportedTypes, ", "))
+	flags.StringVar(&template, "template", "", "a YAML template to create pods from, can be exported from Kubernetes directly via 'kubectl get pods -oyaml', reads stdin if '-'. Additional documents are created as companion objects")
+	flags.IntVar(&podN, "pods", 1, "the amount of pods to create")
+	flags.BoolVar(&skipDelete, "skip-delete", false, "skip removing the pods after they're ready if true")
+	flags.BoolVar(&dryRun, "dry-run", false, "submit each pod in dry-run mode right before creating it and report the latency of both create calls, to tell the time spent in admission, i.e. in webhooks, apart")
+	flags.BoolVar(&prepull, "prepull", false, "prepull all used images to all Kubernetes nodes")
+	flags.BoolVar(&probe, "probe", false, "probe the pods as soon as they have an IP address and capture latency of that as well")
+	flags.BoolVar(&endpoints, "endpoints", false, "create a Service selecting the pods and capture the latency until each pod is a ready endpoint of it as well")
+	flags.StringVar(&probeSvc, "probe-service", "", "create a Service selecting the pods and capture the latency until each pod served a request through it as well, addressing the Service via its ClusterIP if 'ip' or its DNS name if 'dns'")
+	flags.BoolVar(&timestamps, "timestamps", false, "fetch the startup timestamps the test application reports about itself from each pod and report its runtime overhead and init time as well")
+	flags.StringVar(&cbAddr, "callback", "", "the address to listen on for the test application to report back to as soon as it listens, i.e. ':8090', requires podspeed to be reachable from the pods")
+	flags.StringVar(&cbURL, "callback-url", "", "the URL the pods reach the callback listener on, defaults to the port of -callback on the IP in the POD_IP environment variable")
+	flags.BoolVar(&progress, "progress", term.IsTerminal(int(os.Stderr.Fd())), "show the progress of the run on stderr, updated in place on a terminal and logged periodically otherwise, enabled by default on a terminal")
+	flags.StringVar((*string)(&workload), "workload", string(benchmark.WorkloadPod), "the kind of object to create the pods through, supported values: "+strings.Join(workloadNames(), ", "))
+	flags.StringVar(&scale, "scale", "", "an existing workload to scale up instead of creating pods, in the form of 'deployment/NAME' or 'replicaset/NAME'")
+	flags.IntVar(&scaleFrom, "scale-from", -1, "the amount of replicas to scale the workload given via -scale from, defaults to its current replicas")
+	flags.IntVar(&scaleTo, "scale-to", 0, "the amount of replicas to scale the workload given via -scale to")
+	flags.StringVar(&knativeIngress, "knative-ingress", "", "the address to send requests to Knative Services to, using their host as Host header, defaults to their URL")
+	flags.BoolVar(&knativeScaleFromZero, "knative-scale-from-zero", false, "wait for each Knative Service to scale to zero and measure the cold start of a request afterwards")
+	flags.StringVar(&recordFile, "record", "", "the file to record all watch events and Kubernetes Events of the pods to, to analyze them later via 'podspeed analyze', i.e. 'run.jsonl'")
+	flags.Var(&axes, "axis", "an axis of a matrix run in the form of 'name=value1,value2', can be repeated to run all combinations, supported names: "+strings.Join(matrix.AxisNames(), ", "))
+	flags.StringVar(&contexts, "contexts", "", "a comma-separated list of kubeconfig contexts to run the scenario given as argument against, to compare the clusters side by side")
+	flags.BoolVar(&parallel, "parallel", false, "run the scenario against all -contexts at the same time instead of one after the other")
+	flags.BoolVar(&preflightChecks, "preflight", false, "run the checks of 'podspeed preflight' before the run and abort it if any of them fails")
+	out.register(flags)
+	cluster.register(flags)
+	flags.Parse(args)
+
+	switch flags.NArg() {
+	case 0:
+	case 1:
+		// Everything but how to run is configured by the scenario itself.
+		flags.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "progress", "kubeconfig", "context", "as", "qps", "burst", "contexts", "parallel", "preflight":
+			default:
+				log.Fatalf("-%s cannot be combined with a scenario, configure it in the scenario instead", f.Name)
+			}
+		})
+		var kubeContexts []string
+		if contexts != "" {
+			if cluster.context != "" {
+				log.Fatalln("-context cannot be combined with -contexts")
+			}
+			kubeContexts = strings.Split(contexts, ",")
+		}
+		runScenario(flags.Arg(0), progress, cluster, kubeContexts, parallel, preflightChecks)
+		return
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+	if contexts != "" || parallel {
+		log.Fatalln("-contexts and -parallel require a scenario")
+	}
 
 	podFn, err := podtypes.GetConstructor(typ)
 	if err != nil {
 		log.Fatalln("failed to load constructor, valid values for -typ are: ", supportedTypes, err)
 	}
 
+	var (
+		companions      func(podtemplate.Scope, string, string) ([]runtime.Object, error)
+		templateContent []byte
+	)
 	if template != "" {
-		var content io.Reader
-		if template == "-" {
-			content = os.Stdin
-		} else {
-			file, err := os.Open(template)
-			if err != nil {
-				log.Fatalln("Failed to open template file", err)
-			}
-			content = file
+		templateContent, err = readTemplate(template)
+		if err != nil {
+			log.Fatalln("Failed to read template", err)
 		}
-		fn, err := podtemplate.PodConstructorFromYAML(content)
+		t, err := podtemplate.Parse(bytes.NewReader(templateContent))
 		if err != nil {
 			log.Fatalln("Failed to generate template from file", err)
 		}
-		podFn = fn
+		// Keep the ID short as it might end up in names with tight length limits.
+		id := uuid.NewString()[:8]
+		podFn = t.PodConstructor(id)
+		if t.HasCompanions() {
+			companions = func(scope podtemplate.Scope, ns, pod string) ([]runtime.Object, error) {
+				return t.Companions(scope, id, ns, pod)
+			}
+		}
+	}
+
+	if _, ok := out.push.labels["template"]; !ok {
+		out.push.labels["template"] = typ
+		if template != "" && template != "-" {
+			out.push.labels["template"] = filepath.Base(template)
+		}
+		if scale != "" {
+			out.push.labels["template"] = scale
+		}
+	}
+
+	templateName := typ
+	if template != "" {
+		templateName = template
+	}
+	out.metadata, err = newMetadata(templateName, templateContent, flagValues(flags))
+	if err != nil {
+		log.Fatalln("Failed to describe the run", err)
 	}
 
 	if podN < 1 {
 		log.Fatalln("-pods must not be smaller than 1")
 	}
 
+	if !workload.Supported() && workload != workloadKnative {
+		log.Fatalln("-workload must be one of", strings.Join(workloadNames(), ", "))
+	}
+
+	switch benchmark.ServiceProbe(probeSvc) {
+	case "", benchmark.ServiceProbeIP, benchmark.ServiceProbeDNS:
+	default:
+		log.Fatalln("-probe-service must be one of", benchmark.ServiceProbeIP, benchmark.ServiceProbeDNS)
+	}
+
+	variants, err := matrix.Variants(podFn, axes)
+	if err != nil {
+		log.Fatalln("Failed to generate matrix variants", err)
+	}
+
+	if recordFile != "" && (len(axes) > 0 || scale != "" || workload == workloadKnative) {
+		log.Fatalln("-record cannot be combined with -axis, -scale or -workload knative")
+	}
+
+	if dryRun && (workload != benchmark.WorkloadPod || scale != "") {
+		log.Fatalln("-dry-run is only supported for bare pods, it cannot be combined with -workload or -scale")
+	}
+
+	if preflightChecks && scale != "" {
+		log.Fatalln("-preflight cannot be combined with -scale")
+	}
+
+	if workload == workloadKnative && len(axes) > 0 {
+		log.Fatalln("-workload knative cannot be combined with -axis")
+	}
+
+	if workload == workloadKnative && (out.details || out.report != "" || out.results != "" || out.traces.enabled() || out.push.gateway != "" || out.push.remoteWrite != "") {
+		log.Fatalln("-workload knative only prints a summary, it cannot be combined with -details, -report, -results, -trace, -otlp-endpoint, -otlp-file, -pushgateway or -remote-write")
+	}
+
+	if namespaceN < 0 {
+		log.Fatalln("-namespaces must not be negative")
+	}
+	if (createNamespace && namespaceN > 0) || (createNamespace && namespaceSelector != "") || (namespaceN > 0 && namespaceSelector != "") {
+		log.Fatalln("Only one of -create-namespace, -namespaces and -namespace-selector can be used")
+	}
+	spread := namespaceN > 0 || namespaceSelector != ""
+	if createNamespace || spread {
+		flags.Visit(func(f *flag.Flag) {
+			if f.Name == "n" {
+				log.Fatalln("-n cannot be combined with -create-namespace, -namespaces or -namespace-selector")
+			}
+		})
+		if scale != "" {
+			log.Fatalln("-create-namespace, -namespaces and -namespace-selector cannot be combined with -scale")
+		}
+	}
+	if spread && (workload != benchmark.WorkloadPod || endpoints || probeSvc != "") {
+		log.Fatalln("-namespaces and -namespace-selector are only supported for bare pods, they cannot be combined with -workload, -endpoints or -probe-service")
+	}
+	if len(namespaceLabels) > 0 && !createNamespace && namespaceN == 0 {
+		log.Fatalln("-namespace-label requires -create-namespace or -namespaces")
+	}
+
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	// Load kubernetes config.
-	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-		clientcmd.NewDefaultClientConfigLoadingRules(), nil).ClientConfig()
-	if err != nil {
-		log.Fatalln("Failed to load config", err)
+	config, kube, calls := cluster.recordedClient()
+
+	// The namespaces created for the run are deleted whatever its outcome,
+	// unless the pods are meant to be kept.
+	var created, namespaces []string
+	switch {
+	case createNamespace:
+		ns, err = namespace.Create(ctx, kube, namespaceLabels)
+		if err != nil {
+			log.Fatalln("Failed to create namespace", err)
+		}
+		log.Println("Created namespace", ns)
+		created = []string{ns}
+	case namespaceN > 0:
+		created, err = createNamespaces(ctx, kube, namespaceN, namespaceLabels)
+		if err != nil {
+			log.Fatalln("Failed to create namespaces", err)
+		}
+		namespaces = created
+	case namespaceSelector != "":
+		namespaces, err = selectNamespaces(ctx, kube, namespaceSelector)
+		if err != nil {
+			log.Fatalln("Failed to select namespaces", err)
+		}
+		log.Printf("Spreading pods across %d namespaces", len(namespaces))
+	}
+	if len(namespaces) > 0 {
+		// Everything not spread across the namespaces goes to the first one.
+		ns = namespaces[0]
+	}
+	cleanup := func() {
+		if len(created) == 0 {
+			return
+		}
+		if skipDelete {
+			log.Println("Keeping namespaces", strings.Join(created, ", "))
+			return
+		}
+		if err := deleteNamespaces(kube, created); err != nil {
+			log.Fatalln("Failed to delete namespace", err)
+		}
+	}
+	fail := func(v ...interface{}) {
+		if !skipDelete {
+			if err := deleteNamespaces(kube, created); err != nil {
+				log.Println("Failed to delete namespace", err)
+			}
+		}
+		log.Fatalln(v...)
 	}
 
-	// Create kubernetes client.
-	kube, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		log.Fatalln("Failed to create Kubernetes client", err)
+	if preflightChecks {
+		checkOpts := preflight.Options{
+			Namespace:    ns,
+			Namespaces:   namespaces,
+			PodFn:        podFn,
+			Pods:         podN,
+			Knative:      workload == workloadKnative,
+			Endpoints:    endpoints,
+			ServiceProbe: probeSvc != "",
+			Events:       out.events() || recordFile != "",
+			Prepull:      prepull,
+			Companions:   companions,
+		}
+		if !checkOpts.Knative {
+			checkOpts.Workloads = []benchmark.Workload{workload}
+		}
+		if !runChecks(ctx, kube, checkOpts) {
+			fail("Some preflight checks failed")
+		}
+		fmt.Println()
 	}
 
 	if prepull {
 		log.Println("Prepulling images to all nodes")
 		if err := prepullImages(ctx, kube.AppsV1().DaemonSets(ns), podFn(ns, "").Spec); err != nil {
-			log.Fatalln("Failed to prepull images", err)
+			fail("Failed to prepull images", err)
 		}
 		log.Println("Prepulling done")
 	}
 
-	runLabels := labels.Set{
-		"podspeed/run": uuid.NewString(),
-	}
-	watcher, err := kube.CoreV1().Pods(ns).Watch(ctx, metav1.ListOptions{
-		LabelSelector: runLabels.String(),
-	})
-	if err != nil {
-		log.Fatalln("Failed to setup watch for pods", err)
+	if workload == workloadKnative {
+		err := runKnative(ctx, config, kube, knative.Options{
+			Namespace:     ns,
+			Prefix:        typ,
+			PodFn:         podFn,
+			Services:      podN,
+			Ingress:       knativeIngress,
+			ScaleFromZero: knativeScaleFromZero,
+			SkipDelete:    skipDelete,
+		})
+		if err != nil {
+			fail("Failed to run Knative benchmark", err)
+		}
+		printCalls(calls, "")
+		cleanup()
+		return
 	}
 
-	pods := make([]*corev1.Pod, 0, podN)
-	stats := make(map[string]*pod.Stats, podN)
+	if scale != "" {
+		parts := strings.SplitN(scale, "/", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			fail("-scale must be of the form 'deployment/NAME' or 'replicaset/NAME'")
+		}
+		scaleWorkload, name := parts[0], parts[1]
+		if len(axes) > 0 {
+			fail("-scale cannot be combined with -axis")
+		}
+
+		scaleOpts := benchmark.ScaleOptions{
+			Namespace:  ns,
+			Workload:   benchmark.Workload(scaleWorkload),
+			Name:       name,
+			From:       int32(scaleFrom),
+			To:         int32(scaleTo),
+			SkipDelete: skipDelete,
+			Probe:      probe,
+		}
+		stopProgress := func() {}
+		if progress {
+			scaleOpts.Tracker = pod.NewTracker()
+			// The amount of new pods is unknown if scaling from the current
+			// amount of replicas.
+			total := 0
+			if scaleFrom >= 0 {
+				total = scaleTo - scaleFrom
+			}
+			stopProgress = showProgress(ctx, scaleOpts.Tracker, total)
+		}
+		result, err := benchmark.Scale(ctx, kube, scaleOpts)
+		stopProgress()
+		if err != nil {
+			fail("Failed to run scale benchmark", err)
+		}
+
+		fmt.Printf("Scaled %s to %d replicas, results of the %d new pods are in ms:\n", scale, scaleTo, len(result.Stats))
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
+		fmt.Fprintln(w, "metric\tmin\tmax\tmean\tmedian\tp25\tp75\tp95\tp99")
+		for _, m := range metricsFor(runInfo{Workload: benchmark.Workload(scaleWorkload), Probe: probe}) {
+			printStats(w, m.label, durations(result.Stats, m.fn))
+		}
+		w.Flush()
 
-	for i := 0; i < podN; i++ {
-		p := podFn(ns, typ+"-"+uuid.NewString())
-		p.Labels = runLabels
-		pods = append(pods, p)
-		stats[p.Name] = &pod.Stats{}
+		fmt.Println()
+		fmt.Printf("All replicas were available after %d ms\n", result.TimeToAvailable/time.Millisecond)
+		if out.details {
+			fmt.Println()
+			fmt.Println("Details:")
+			printDetails(result)
+		}
+		printCalls(calls, "")
+		info := runInfo{Namespace: ns, Type: scale, Pods: len(result.Stats), Workload: benchmark.Workload(scaleWorkload), Probe: probe}
+		if err := out.export(ctx, info, result); err != nil {
+			fail("Failed to export results", err)
+		}
+		return
 	}
 
-	readyCh := make(chan struct{}, podN)
-	deletedCh := make(chan struct{}, podN)
-	ipCh := make(chan *corev1.Pod, podN)
-	probedCh := make(chan struct{}, podN)
-	go func() {
-		for event := range watcher.ResultChan() {
-			now := time.Now()
-			switch event.Type {
-			case watch.Added:
-				p := event.Object.(*corev1.Pod)
-				stats := stats[p.Name]
-				stats.Created = now
-				if p.Status.PodIP != "" && stats.HasIP.IsZero() {
-					stats.HasIP = now
-					ipCh <- p
-				}
-			case watch.Modified:
-				p := event.Object.(*corev1.Pod)
-				stats := stats[p.Name]
-
-				if p.Status.PodIP != "" && stats.HasIP.IsZero() {
-					stats.HasIP = now
-					ipCh <- p
-				}
-				if pod.IsConditionTrue(p, corev1.PodScheduled) && stats.Scheduled.IsZero() {
-					stats.Scheduled = now
-				}
-				if pod.IsConditionTrue(p, corev1.PodInitialized) && stats.Initialized.IsZero() {
-					stats.Initialized = now
-				}
-				if pod.IsConditionTrue(p, corev1.ContainersReady) && stats.ContainersReady.IsZero() {
-					stats.ContainersReady = now
-				}
-				if pod.IsConditionTrue(p, corev1.PodReady) && stats.Ready.IsZero() {
-					stats.Ready = now
-					stats.ContainersStarted = pod.LastContainerStartedTime(p)
-					readyCh <- struct{}{}
-				}
-			case watch.Deleted:
-				deletedCh <- struct{}{}
+	var callbacks chan callback.Callback
+	if cbAddr != "" {
+		if cbURL == "" {
+			cbURL, err = defaultCallbackURL(cbAddr)
+			if err != nil {
+				fail("Failed to determine callback URL, consider setting -callback-url", err)
 			}
 		}
-	}()
-
-	if probe {
+		callbacks = make(chan callback.Callback, podN)
 		go func() {
-			for p := range ipCh {
-				// TODO: Probe path needs to be adjustable per app.
-				url := "http://" + p.Status.PodIP + ":8012"
-				wait.PollImmediateInfinite(10*time.Millisecond, func() (bool, error) {
-					resp, err := http.Get(url)
-					if err != nil {
-						return false, nil
-					}
-					defer resp.Body.Close()
-					return resp.StatusCode == http.StatusOK, nil
-				})
-				stats[p.Name].Probed = time.Now()
-				probedCh <- struct{}{}
+			if err := callback.Serve(ctx, cbAddr, callbacks); err != nil {
+				fail("Failed to serve callbacks", err)
 			}
 		}()
 	}
 
-	for _, p := range pods {
-		if _, err := kube.CoreV1().Pods(p.Namespace).Create(ctx, p, metav1.CreateOptions{}); err != nil {
-			log.Fatalln("Failed to create pod", err)
-		}
+	out.metadata.Clusters = []clusterMetadata{collectCluster(ctx, kube, cluster.context)}
+
+	opts := benchmark.Options{
+		Namespace:    ns,
+		Namespaces:   namespaces,
+		Prefix:       typ,
+		PodFn:        podFn,
+		Pods:         podN,
+		Workload:     workload,
+		SkipDelete:   skipDelete,
+		DryRun:       dryRun,
+		Probe:        probe,
+		Endpoints:    endpoints,
+		ServiceProbe: benchmark.ServiceProbe(probeSvc),
+		Timestamps:   timestamps,
+		CallbackURL:  cbURL,
+		Callbacks:    callbacks,
+		Companions:   companions,
+		Events:       out.events() || recordFile != "",
+	}
+	info := infoFor(typ, opts)
 
-		// Wait for all pods to become ready.
-		if err := waitForN(ctx, readyCh, 1); err != nil {
-			log.Fatalln("Failed to wait for pod becoming ready", err)
+	if len(axes) == 0 {
+		stopProgress := func() {}
+		if progress {
+			opts.Tracker = pod.NewTracker()
+			stopProgress = showProgress(ctx, opts.Tracker, podN)
 		}
-		if probe {
-			// And for all pods to be probed, if we're doing that.
-			if err := waitForN(ctx, probedCh, 1); err != nil {
-				log.Fatal("Failed to wait for pod be probed", err)
+		var recorder *record.Recorder
+		if recordFile != "" {
+			file, err := os.Create(recordFile)
+			if err != nil {
+				fail("Failed to create recording", err)
 			}
+			defer file.Close()
+			recorder = record.NewRecorder(file)
+			recorder.Header(recordingHeader{runInfo: info, Metadata: out.metadata})
+			opts.Recorder = recorder
 		}
 
-		if !skipDelete {
-			var zero int64
-			if err := kube.CoreV1().Pods(p.Namespace).Delete(ctx, p.Name, metav1.DeleteOptions{
-				GracePeriodSeconds: &zero,
-			}); err != nil {
-				log.Fatalln("Failed to delete pod", err)
+		result, err := benchmark.Run(ctx, kube, opts)
+		stopProgress()
+		if err != nil {
+			fail("Failed to run benchmark", err)
+		}
+		if recorder != nil {
+			// The Stats have been recorded individually already.
+			recorder.Result(benchmark.Result{
+				TimeToAvailable: result.TimeToAvailable,
+				TimeToCompleted: result.TimeToCompleted,
+			})
+			if err := recorder.Close(); err != nil {
+				fail("Failed to write recording", err)
 			}
+		}
 
-			waitForN(ctx, deletedCh, 1)
+		printResult(info, result, out.details)
+		printCalls(calls, "")
+		if err := out.export(ctx, info, result); err != nil {
+			fail("Failed to export results", err)
 		}
+		cleanup()
+		return
 	}
 
-	timeToScheduled := make([]float64, 0, len(stats))
-	timeToIP := make([]float64, 0, len(stats))
-	timeToProbed := make([]float64, 0, len(stats))
-	timeToReady := make([]float64, 0, len(stats))
-	for _, stat := range stats {
-		timeToScheduled = append(timeToScheduled, float64(stat.TimeToScheduled()/time.Millisecond))
-		timeToIP = append(timeToIP, float64(stat.TimeToIP()/time.Millisecond))
-		timeToProbed = append(timeToProbed, float64(stat.TimeToProbed()/time.Millisecond))
-		timeToReady = append(timeToReady, float64(stat.TimeToReady()/time.Millisecond))
+	// Run all variants with the same settings, one after the other.
+	results := make([]labelledResult, 0, len(variants))
+	for i, variant := range variants {
+		log.Printf("Running variant %d/%d: %s", i+1, len(variants), variant.Name)
+		variantOpts := opts
+		variantOpts.PodFn = variant.PodFn
+		stopProgress := func() {}
+		if progress {
+			variantOpts.Tracker = pod.NewTracker()
+			stopProgress = showProgress(ctx, variantOpts.Tracker, podN)
+		}
+		result, err := benchmark.Run(ctx, kube, variantOpts)
+		stopProgress()
+		if err != nil {
+			fail(fmt.Sprintf("Failed to run variant %s:", variant.Name), err)
+		}
+		results = append(results, labelledResult{label: variant.Name, info: info, result: result})
 	}
 
-	fmt.Printf("Created %d %s pods sequentially, results are in ms:\n", podN, typ)
-	fmt.Println()
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
-	fmt.Fprintln(w, "metric\tmin\tmax\tmean\tmedian\tp25\tp75\tp95\tp99")
-	printStats(w, "Time to scheduled", timeToScheduled)
-	printStats(w, "Time to ip", timeToIP)
-	if probe {
-		printStats(w, "Time to probed", timeToProbed)
+	if workload == benchmark.WorkloadPod {
+		fmt.Printf("Created %d %s pods sequentially for each of %d variants, results are in ms:\n", podN, typ, len(variants))
+	} else {
+		fmt.Printf("Created a %s with %d %s pods for each of %d variants, results are in ms:\n", workload, podN, typ, len(variants))
 	}
-	printStats(w, "Time to ready", timeToReady)
-	w.Flush()
+	printResults("variant", results)
+	printCalls(calls, "")
 
-	if details {
-		fmt.Println()
-		fmt.Println("Details:")
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
-		fmt.Fprintln(w, "pod\tto scheduled\tto ip\tto ready")
-		for name, stat := range stats {
-			fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", name,
-				stat.TimeToScheduled()/time.Millisecond,
-				stat.TimeToIP()/time.Millisecond,
-				stat.TimeToReady()/time.Millisecond)
+	title := fmt.Sprintf("podspeed: %d %s pods (%s) for each of %d variants", podN, typ, workload, len(variants))
+	if err := out.exportAll(ctx, title, "variant", results); err != nil {
+		fail("Failed to export results", err)
+	}
+	cleanup()
+}
+
+// metric is a single metric reported in the summary tables.
+type metric struct {
+	label string
+	// name identifies the metric in exported results.
+	name string
+	fn   func(pod.Stats) time.Duration
+}
+
+// metricsFor returns the metrics that are available for the given run.
+func metricsFor(opts runInfo) []metric {
+	var metrics []metric
+	if opts.Workload == benchmark.WorkloadPod {
+		if opts.DryRun {
+			metrics = append(metrics, metric{label: "Dry-run create call", name: "dry_run_create_call", fn: pod.Stats.DryRunLatency})
 		}
-		w.Flush()
+		metrics = append(metrics, metric{label: "Create call", name: "create_call", fn: pod.Stats.CreateLatency})
+	} else {
+		metrics = append(metrics, metric{label: "Time to created", name: "created", fn: pod.Stats.TimeToCreated})
+	}
+	metrics = append(metrics,
+		metric{label: "Time to scheduled", name: "scheduled", fn: pod.Stats.TimeToScheduled},
+		metric{label: "Time to ip", name: "ip", fn: pod.Stats.TimeToIP})
+	if opts.Workload == benchmark.WorkloadJob {
+		// Pods of Jobs are not necessarily ever ready.
+		return append(metrics, metric{label: "Time to completed", name: "completed", fn: pod.Stats.TimeToCompleted})
+	}
+	if opts.Probe {
+		metrics = append(metrics, metric{label: "Time to probed", name: "probed", fn: pod.Stats.TimeToProbed})
+	}
+	metrics = append(metrics, metric{label: "Time to ready", name: "ready", fn: pod.Stats.TimeToReady})
+	if opts.Endpoints {
+		metrics = append(metrics, metric{label: "Time to endpoint", name: "endpoint", fn: pod.Stats.TimeToEndpoint})
+	}
+	if opts.ServiceProbe != "" {
+		metrics = append(metrics, metric{label: "Time to probed through service", name: "service_probed", fn: pod.Stats.TimeToServiceProbed})
+	}
+	if opts.Callbacks {
+		metrics = append(metrics, metric{label: "Time to callback", name: "callback", fn: pod.Stats.TimeToCallback})
+	}
+	if opts.Timestamps {
+		metrics = append(metrics,
+			metric{label: "Time to process started", name: "process_started", fn: pod.Stats.TimeToProcessStarted},
+			metric{label: "Runtime overhead", name: "runtime_overhead", fn: pod.Stats.RuntimeOverhead},
+			metric{label: "App init time", name: "init", fn: pod.Stats.InitTime})
+		// Fetching the timestamps doesn't count as a request, so only probes
+		// send one.
+		if opts.Probe || opts.ServiceProbe != "" {
+			metrics = append(metrics, metric{label: "Time to first request", name: "first_request", fn: pod.Stats.TimeToFirstRequest})
+		}
+	}
+	if opts.Workload != benchmark.WorkloadPod {
+		metrics = append(metrics, metric{label: "Time from request to ready", name: "request_to_ready", fn: pod.Stats.TimeFromRequestToReady})
 	}
+	return metrics
 }
 
-func waitForN(ctx context.Context, ch chan struct{}, n int) error {
-	var seen int
-	for {
-		select {
-		case <-ch:
-			seen++
-			if seen == n {
-				return nil
-			}
-		case <-ctx.Done():
-			return ctx.Err()
+// defaultCallbackURL builds the URL of the callback listener from the POD_IP
+// environment variable, as set in job.yaml.
+func defaultCallbackURL(addr string) (string, error) {
+	ip := os.Getenv("POD_IP")
+	if ip == "" {
+		return "", errors.New("POD_IP is not set")
+	}
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse -callback: %w", err)
+	}
+	return "http://" + net.JoinHostPort(ip, port), nil
+}
+
+// loadTemplate parses the template at the given path, reading stdin if it's
+// '-'.
+func loadTemplate(path string) (*podtemplate.Template, error) {
+	content, err := readTemplate(path)
+	if err != nil {
+		return nil, err
+	}
+	return podtemplate.Parse(bytes.NewReader(content))
+}
+
+// readTemplate reads the template at the given path, reading stdin if it's
+// '-'.
+func readTemplate(path string) ([]byte, error) {
+	if path == "-" {
+		content, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template from stdin: %w", err)
+		}
+		return content, nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template file: %w", err)
+	}
+	return content, nil
+}
+
+// showProgress renders the progress of the pods recorded by the given tracker
+// on stderr until the returned function is called.
+func showProgress(ctx context.Context, tracker *pod.Tracker, total int) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		progress.New(tracker, total, os.Stderr).Run(ctx)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// durations extracts the given duration from all stats in milliseconds,
+// skipping pods it's unknown for.
+func durations(stats map[string]*pod.Stats, fn func(pod.Stats) time.Duration) []float64 {
+	data := make([]float64, 0, len(stats))
+	for _, stat := range stats {
+		if d := fn(*stat); d != pod.Unknown {
+			data = append(data, float64(d/time.Millisecond))
+		}
+	}
+	return data
+}
+
+func workloadNames() []string {
+	return append(benchmark.WorkloadNames(), workloadKnative)
+}
+
+// axesFlag collects the axes of a matrix run from repeated flags.
+type axesFlag []matrix.Axis
+
+func (a *axesFlag) String() string {
+	return fmt.Sprint(*a)
+}
+
+func (a *axesFlag) Set(value string) error {
+	axis, err := matrix.ParseAxis(value)
+	if err != nil {
+		return err
+	}
+	for _, existing := range *a {
+		if existing.Name == axis.Name {
+			return fmt.Errorf("axis %q is given more than once, list all of its values in one flag instead", axis.Name)
 		}
 	}
+	*a = append(*a, axis)
+	return nil
 }
 
 func printStats(w io.Writer, label string, data []float64) {
@@ -286,41 +700,3 @@ func printStats(w io.Writer, label string, data []float64) {
 	p99, _ := statistics.Percentile(data, 99)
 	fmt.Fprintf(w, "%s\t%.0f\t%.0f\t%.0f\t%.0f\t%.0f\t%.0f\t%.0f\t%.0f\n", label, min, max, mean, median, p25, p75, p95, p99)
 }
-
-func prepullImages(ctx context.Context, client clientappsv1.DaemonSetInterface, podSpec corev1.PodSpec) error {
-	labels := map[string]string{
-		"podspeed/warmup": "true",
-	}
-	ds := &appsv1.DaemonSet{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: "warmup",
-		},
-		Spec: appsv1.DaemonSetSpec{
-			Selector: &metav1.LabelSelector{
-				MatchLabels: labels,
-			},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: labels,
-				},
-				Spec: podSpec,
-			},
-		},
-	}
-
-	if _, err := client.Create(ctx, ds, metav1.CreateOptions{}); err != nil {
-		return fmt.Errorf("failed to create DaemonSet: %w", err)
-	}
-
-	if err := wait.PollImmediate(1*time.Second, 3*time.Minute, func() (bool, error) {
-		got, err := client.Get(ctx, ds.Name, metav1.GetOptions{})
-		if err != nil {
-			return false, fmt.Errorf("failed to fetch DaemonSet: %w", err)
-		}
-		return got.Status.NumberReady > 0 && got.Status.NumberReady == got.Status.DesiredNumberScheduled, nil
-	}); err != nil {
-		return fmt.Errorf("DaemonSet never became ready: %w", err)
-	}
-
-	return client.Delete(ctx, ds.Name, metav1.DeleteOptions{})
-}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/markusthoemmes/podspeed/pkg/cluster"
+	podtypes "github.com/markusthoemmes/podspeed/pkg/pod/types"
+	"github.com/markusthoemmes/podspeed/pkg/report"
+	"k8s.io/client-go/kubernetes"
+)
+
+// runMetadata describes how and where results were produced, to make sense
+// of them long after the run.
+type runMetadata struct {
+	Podspeed string           `json:"podspeed"`
+	Template templateMetadata `json:"template"`
+	// Options are the effective options of the run, including defaults.
+	Options  interface{}       `json:"options"`
+	Clusters []clusterMetadata `json:"clusters,omitempty"`
+}
+
+type templateMetadata struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+}
+
+type clusterMetadata struct {
+	// Context is the kubeconfig context, if several clusters are compared.
+	Context string `json:"context,omitempty"`
+	cluster.Info
+	// Error is why the Info couldn't be collected, if so.
+	Error string `json:"error,omitempty"`
+}
+
+// newMetadata returns the metadata of a run of the given template, which is
+// either the name of a built-in type or the content of a template file.
+func newMetadata(name string, content []byte, options interface{}) (*runMetadata, error) {
+	if content == nil {
+		manifest, err := podtypes.Manifest(name)
+		if err != nil {
+			return nil, err
+		}
+		content = manifest
+	} else {
+		name = filepath.Base(name)
+	}
+	hash := sha256.Sum256(content)
+	return &runMetadata{
+		Podspeed: podspeedVersion(),
+		Template: templateMetadata{Name: name, SHA256: hex.EncodeToString(hash[:])},
+		Options:  options,
+	}, nil
+}
+
+// collectCluster describes the cluster of the given client. Failing to do so,
+// i.e. for lack of permissions to list nodes, doesn't fail the run.
+func collectCluster(ctx context.Context, kube kubernetes.Interface, kubeContext string) clusterMetadata {
+	m := clusterMetadata{Context: kubeContext}
+	info, err := cluster.Collect(ctx, kube)
+	if err != nil {
+		log.Println("Failed to collect cluster metadata", err)
+		m.Error = err.Error()
+		return m
+	}
+	m.Info = *info
+	return m
+}
+
+// flagValues returns the effective values of all flags.
+func flagValues(flags *flag.FlagSet) map[string]string {
+	values := make(map[string]string)
+	flags.VisitAll(func(f *flag.Flag) {
+		values[f.Name] = f.Value.String()
+	})
+	return values
+}
+
+// properties lists the metadata for the report.
+func (m *runMetadata) properties() []report.Property {
+	props := []report.Property{
+		{Name: "podspeed", Value: m.Podspeed},
+		{Name: "template", Value: m.Template.Name},
+		{Name: "template sha256", Value: m.Template.SHA256},
+	}
+	for _, c := range m.Clusters {
+		prefix := ""
+		if c.Context != "" {
+			prefix = c.Context + ": "
+		}
+		if c.Error != "" {
+			props = append(props, report.Property{Name: prefix + "cluster", Value: c.Error})
+			continue
+		}
+		props = append(props,
+			report.Property{Name: prefix + "server version", Value: c.ServerVersion},
+			report.Property{Name: prefix + "nodes", Value: fmt.Sprint(c.NodeCount)},
+			report.Property{Name: prefix + "kubelet versions", Value: distinct(c.Nodes, func(n cluster.Node) string { return n.KubeletVersion })},
+			report.Property{Name: prefix + "container runtimes", Value: distinct(c.Nodes, func(n cluster.Node) string { return n.ContainerRuntimeVersion })},
+			report.Property{Name: prefix + "kernels", Value: distinct(c.Nodes, func(n cluster.Node) string { return n.KernelVersion })},
+			report.Property{Name: prefix + "OS images", Value: distinct(c.Nodes, func(n cluster.Node) string { return n.OSImage })},
+			report.Property{Name: prefix + "instance types", Value: distinct(c.Nodes, func(n cluster.Node) string { return n.InstanceType })},
+			report.Property{Name: prefix + "zones", Value: distinct(c.Nodes, func(n cluster.Node) string { return n.Zone })},
+		)
+	}
+
+	options := ""
+	if values, ok := m.Options.(map[string]string); ok {
+		pairs := make([]string, 0, len(values))
+		for k, v := range values {
+			pairs = append(pairs, k+"="+v)
+		}
+		sort.Strings(pairs)
+		options = strings.Join(pairs, " ")
+	} else if encoded, err := json.Marshal(m.Options); err == nil {
+		options = string(encoded)
+	}
+	return append(props, report.Property{Name: "options", Value: options})
+}
+
+// distinct summarizes a property of the nodes as its distinct values and how
+// many nodes have each.
+func distinct(nodes []cluster.Node, fn func(cluster.Node) string) string {
+	counts := make(map[string]int)
+	for _, n := range nodes {
+		if v := fn(n); v != "" {
+			counts[v]++
+		}
+	}
+	values := make([]string, 0, len(counts))
+	for v, n := range counts {
+		values = append(values, fmt.Sprintf("%s (%d)", v, n))
+	}
+	sort.Strings(values)
+	return strings.Join(values, ", ")
+}
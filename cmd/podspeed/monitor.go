@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/benchmark"
+	"github.com/markusthoemmes/podspeed/pkg/monitor"
+	podtypes "github.com/markusthoemmes/podspeed/pkg/pod/types"
+)
+
+// commandMonitor is the first argument that starts the monitor mode.
+const commandMonitor = "monitor"
+
+// runMonitor continuously measures the startup of pods and serves the results
+// as Prometheus metrics until interrupted.
+func runMonitor(args []string) {
+	var (
+		ns       string
+		typ      string
+		template string
+		probe    bool
+		addr     string
+		interval time.Duration
+		timeout  time.Duration
+		cluster  kubeFlags
+	)
+
+	supportedTypes, err := podtypes.Names()
+	if err != nil {
+		log.Fatalln("failed to built in types: ", err)
+	}
+
+	flags := newFlagSet(commandMonitor)
+	flags.StringVar(&ns, "n", "default", "the namespace to create the pods in")
+	flags.StringVar(&typ, "typ", "basic", "the type of pods to create, supported values: "+strings.Join(supportedTypes, ", "))
+	flags.StringVar(&template, "template", "", "a YAML template to create pods from, reads stdin if '-', must not contain companion objects")
+	flags.BoolVar(&probe, "probe", false, "probe the pods as soon as they have an IP address and capture latency of that as well")
+	flags.StringVar(&addr, "addr", ":9090", "the address to serve metrics on at /metrics")
+	flags.DurationVar(&interval, "interval", time.Minute, "the time between the start of two measurements")
+	flags.DurationVar(&timeout, "timeout", 5*time.Minute, "the time a single measurement may take before it's considered failed")
+	cluster.register(flags)
+	flags.Parse(args)
+
+	podFn, err := podtypes.GetConstructor(typ)
+	if err != nil {
+		log.Fatalln("failed to load constructor, valid values for -typ are: ", supportedTypes, err)
+	}
+	if template != "" {
+		t, err := loadTemplate(template)
+		if err != nil {
+			log.Fatalln("Failed to generate template from file", err)
+		}
+		if t.HasCompanions() {
+			log.Fatalln("Templates with companion objects are not supported in monitor mode")
+		}
+		podFn = t.PodConstructor("")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	_, kube := cluster.client()
+
+	m := monitor.New(kube, monitor.Options{
+		Benchmark: benchmark.Options{
+			Namespace: ns,
+			Prefix:    typ,
+			PodFn:     podFn,
+			Probe:     probe,
+		},
+		Interval: interval,
+		Timeout:  timeout,
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Registry().Handler())
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalln("Failed to serve metrics", err)
+		}
+	}()
+
+	log.Printf("Measuring a pod every %v, serving metrics on %s", interval, addr)
+	m.Run(ctx)
+	server.Close()
+}
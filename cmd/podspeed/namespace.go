@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/namespace"
+	"k8s.io/client-go/kubernetes"
+)
+
+// namespaceDeleteTimeout bounds how long deleting a namespace of a run may
+// take, including all pods in it terminating.
+const namespaceDeleteTimeout = 5 * time.Minute
+
+// deleteNamespace deletes the namespace created for a run and waits for it to
+// be gone. It doesn't use the context of the run, to clean up after an
+// interrupted run as well.
+func deleteNamespace(kube kubernetes.Interface, name string) error {
+	log.Println("Deleting namespace", name)
+	ctx, cancel := context.WithTimeout(context.Background(), namespaceDeleteTimeout)
+	defer cancel()
+	if err := namespace.Delete(ctx, kube, name); err != nil {
+		return err
+	}
+	log.Println("Deleted namespace", name)
+	return nil
+}
+
+// createNamespaces creates n namespaces for a run to spread its pods across.
+// If that fails, the ones already created are deleted again.
+func createNamespaces(ctx context.Context, kube kubernetes.Interface, n int, labels map[string]string) ([]string, error) {
+	names := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		name, err := namespace.Create(ctx, kube, labels)
+		if err != nil {
+			if err := deleteNamespaces(kube, names); err != nil {
+				log.Println("Failed to delete namespaces", err)
+			}
+			return nil, err
+		}
+		log.Println("Created namespace", name)
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// deleteNamespaces deletes all given namespaces like deleteNamespace, trying
+// all of them even if some fail.
+func deleteNamespaces(kube kubernetes.Interface, names []string) error {
+	var failed error
+	for _, name := range names {
+		if err := deleteNamespace(kube, name); err != nil {
+			failed = err
+		}
+	}
+	return failed
+}
+
+// selectNamespaces returns the existing namespaces to spread the pods of a run
+// across.
+func selectNamespaces(ctx context.Context, kube kubernetes.Interface, selector string) ([]string, error) {
+	names, err := namespace.Select(ctx, kube, selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no namespaces match %q", selector)
+	}
+	return names, nil
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/benchmark"
+	"github.com/markusthoemmes/podspeed/pkg/metrics"
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+	"github.com/markusthoemmes/podspeed/pkg/report"
+	"github.com/markusthoemmes/podspeed/pkg/trace"
+	statistics "github.com/montanaflynn/stats"
+)
+
+// runInfo describes what was measured in a run. It's recorded as the header of
+// recordings to be able to analyze them later.
+type runInfo struct {
+	Namespace    string                 `json:"namespace"`
+	Namespaces   []string               `json:"namespaces,omitempty"`
+	Type         string                 `json:"type"`
+	Pods         int                    `json:"pods"`
+	Workload     benchmark.Workload     `json:"workload"`
+	Probe        bool                   `json:"probe,omitempty"`
+	Endpoints    bool                   `json:"endpoints,omitempty"`
+	ServiceProbe benchmark.ServiceProbe `json:"serviceProbe,omitempty"`
+	Callbacks    bool                   `json:"callbacks,omitempty"`
+	Timestamps   bool                   `json:"timestamps,omitempty"`
+	DryRun       bool                   `json:"dryRun,omitempty"`
+}
+
+func infoFor(typ string, opts benchmark.Options) runInfo {
+	return runInfo{
+		Namespace:    opts.Namespace,
+		Namespaces:   opts.Namespaces,
+		Type:         typ,
+		Pods:         opts.Pods,
+		Workload:     opts.Workload,
+		Probe:        opts.Probe,
+		Endpoints:    opts.Endpoints,
+		ServiceProbe: opts.ServiceProbe,
+		Callbacks:    opts.Callbacks != nil,
+		Timestamps:   opts.Timestamps,
+		DryRun:       opts.DryRun,
+	}
+}
+
+// labelledResult is the result of one of several runs, i.e. of a variant of a
+// matrix run or a step of a scenario.
+type labelledResult struct {
+	label string
+	// context is the kubeconfig context the run was made against, if several
+	// clusters are compared.
+	context string
+	info    runInfo
+	result  *benchmark.Result
+}
+
+// name identifies the result among all others.
+func (r labelledResult) name() string {
+	if r.context == "" {
+		return r.label
+	}
+	return r.label + " @ " + r.context
+}
+
+// outputs configures what is produced from the results of a run, besides the
+// summary on stdout.
+type outputs struct {
+	// metadata describes the environment of the results, if known.
+	metadata *runMetadata
+	details  bool
+	report   string
+	results  string
+	traces   traceOptions
+	push     pushOptions
+}
+
+// newOutputs returns outputs that produce nothing but the summary.
+func newOutputs() outputs {
+	return outputs{push: pushOptions{labels: metrics.Labels{}}}
+}
+
+// register registers the flags configuring the outputs.
+func (o *outputs) register(flags *flag.FlagSet) {
+	flags.BoolVar(&o.details, "details", false, "print detailed timing information for each pod")
+	flags.StringVar(&o.push.gateway, "pushgateway", "", "the URL of a Prometheus Pushgateway to push the results to at the end of the run")
+	flags.StringVar(&o.push.remoteWrite, "remote-write", "", "the URL of a Prometheus remote-write endpoint to push the results to at the end of the run")
+	flags.Var(labelsFlag(o.push.labels), "push-label", "a label in the form of 'name=value' to add to the pushed results, i.e. 'cluster=staging', can be repeated, 'template' defaults to the name of the template or type")
+	flags.StringVar(&o.traces.endpoint, "otlp-endpoint", "", "the OTLP/HTTP endpoint to export a trace of each pod's startup to at the end of the run, i.e. 'http://collector:4318'")
+	flags.StringVar(&o.traces.file, "otlp-file", "", "the file to write a trace of each pod's startup to at the end of the run, in the JSON encoding of OTLP")
+	flags.StringVar(&o.results, "results", "", "the file to write the results of the run to as JSON, to compare them later, i.e. 'results.json'")
+	flags.StringVar(&o.report, "report", "", "the file to write a self-contained HTML report of the run to, i.e. 'report.html'")
+	flags.StringVar(&o.traces.chrome, "trace", "", "the file to write a timeline of the run to at the end of it, in the Chrome Trace Event format as understood by Perfetto, i.e. 'trace.json'")
+}
+
+// events returns true if any output needs the Kubernetes Events of the pods.
+func (o outputs) events() bool {
+	return o.report != "" || o.traces.enabled()
+}
+
+// printResult prints the summary of a single run to stdout.
+func printResult(info runInfo, result *benchmark.Result, details bool) {
+	if info.Workload == benchmark.WorkloadPod {
+		fmt.Printf("Created %d %s pods sequentially, results are in ms:\n", info.Pods, info.Type)
+	} else {
+		fmt.Printf("Created a %s with %d %s pods, results are in ms:\n", info.Workload, info.Pods, info.Type)
+	}
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
+	fmt.Fprintln(w, "metric\tmin\tmax\tmean\tmedian\tp25\tp75\tp95\tp99")
+	for _, m := range metricsFor(info) {
+		printStats(w, m.label, durations(result.Stats, m.fn))
+	}
+	w.Flush()
+
+	if len(info.Namespaces) > 1 {
+		fmt.Println()
+		fmt.Println("Time to ready by namespace:")
+		printNamespaces(result)
+	}
+
+	if result.TimeToAvailable != 0 {
+		fmt.Println()
+		fmt.Printf("All pods were ready after %d ms\n", result.TimeToAvailable/time.Millisecond)
+	}
+	if result.TimeToCompleted != 0 {
+		fmt.Println()
+		fmt.Printf("The job completed after %d ms\n", result.TimeToCompleted/time.Millisecond)
+	}
+
+	if details {
+		fmt.Println()
+		fmt.Println("Details:")
+		printDetails(result)
+	}
+}
+
+// printDetails prints the timing of each pod of the result to stdout.
+func printDetails(result *benchmark.Result) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
+	fmt.Fprintln(w, "pod\tto scheduled\tto ip\tto ready")
+	for name, stat := range result.Stats {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name,
+			formatMillis(stat.TimeToScheduled()),
+			formatMillis(stat.TimeToIP()),
+			formatMillis(stat.TimeToReady()))
+	}
+	w.Flush()
+}
+
+// printNamespaces prints the time to ready of the pods of the result per
+// namespace to stdout.
+func printNamespaces(result *benchmark.Result) {
+	perNamespace := byNamespace(result.Stats)
+	namespaces := make([]string, 0, len(perNamespace))
+	for ns := range perNamespace {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
+	fmt.Fprintln(w, "namespace\tmin\tmax\tmean\tmedian\tp25\tp75\tp95\tp99")
+	for _, ns := range namespaces {
+		printStats(w, ns, durations(perNamespace[ns], pod.Stats.TimeToReady))
+	}
+	w.Flush()
+}
+
+// byNamespace groups the stats of the pods by their namespace.
+func byNamespace(stats map[string]*pod.Stats) map[string]map[string]*pod.Stats {
+	grouped := make(map[string]map[string]*pod.Stats)
+	for name, stat := range stats {
+		if grouped[stat.Namespace] == nil {
+			grouped[stat.Namespace] = make(map[string]*pod.Stats)
+		}
+		grouped[stat.Namespace][name] = stat
+	}
+	return grouped
+}
+
+// formatMillis formats the duration in milliseconds, or as "-" if unknown.
+func formatMillis(d time.Duration) string {
+	if d == pod.Unknown {
+		return "-"
+	}
+	return strconv.FormatInt(int64(d/time.Millisecond), 10)
+}
+
+// printResults prints a table per metric to stdout, with a row for each of
+// the results, and the time to ready per namespace of the results spread
+// across several. The column names what the results are labelled by.
+func printResults(column string, results []labelledResult) {
+	for _, m := range metricsOf(results) {
+		fmt.Println()
+		fmt.Println(m.label + ":")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
+		fmt.Fprintln(w, column+"\tmin\tmax\tmean\tmedian\tp25\tp75\tp95\tp99")
+		for _, r := range results {
+			if hasMetric(r.info, m.name) {
+				printStats(w, r.name(), durations(r.result.Stats, m.fn))
+			}
+		}
+		w.Flush()
+	}
+	for _, r := range results {
+		if len(r.info.Namespaces) > 1 {
+			fmt.Println()
+			fmt.Printf("Time to ready by namespace of %s %s:\n", column, r.name())
+			printNamespaces(r.result)
+		}
+	}
+}
+
+// export writes the result of a single run to all configured outputs.
+func (o outputs) export(ctx context.Context, info runInfo, result *benchmark.Result) error {
+	title := fmt.Sprintf("podspeed: %d %s pods (%s)", info.Pods, info.Type, info.Workload)
+	return o.exportAll(ctx, title, "", []labelledResult{{info: info, result: result}})
+}
+
+// exportAll writes the results of several runs to all configured outputs. The
+// results are distinguished by a label of the given name.
+func (o outputs) exportAll(ctx context.Context, title, labelName string, results []labelledResult) error {
+	var (
+		spans    []trace.Span
+		reported []report.Metric
+		pods     []report.Pod
+	)
+	registry := metrics.NewRegistry()
+	for _, r := range results {
+		labels := make(map[string]string)
+		attributes := make(map[string]string)
+		suffix := ""
+		if r.label != "" {
+			labels[labelName] = r.label
+			attributes["podspeed."+labelName] = r.label
+		}
+		if r.context != "" {
+			labels["context"] = r.context
+			attributes["podspeed.context"] = r.context
+		}
+		if name := r.name(); name != "" {
+			suffix = " (" + name + ")"
+		}
+		runSpans := podSpans(r.info.Namespace, r.result.Stats, attributes)
+		spans = append(spans, runSpans...)
+		pods = append(pods, reportPods(runSpans, r.result.Stats)...)
+		reported = append(reported, reportMetrics(r.result.Stats, metricsFor(r.info), suffix)...)
+		recordResult(registry, labels, r.result, metricsFor(r.info))
+	}
+
+	if o.results != "" {
+		if err := writeResults(o.results, o.metadata, results); err != nil {
+			return err
+		}
+	}
+	var environment []report.Property
+	if o.metadata != nil {
+		environment = o.metadata.properties()
+		recordMetadata(registry, o.metadata)
+	}
+
+	if o.report != "" {
+		if err := writeReport(o.report, report.Report{
+			Title:       title,
+			Environment: environment,
+			Comparison:  comparison(results),
+			Metrics:     reported,
+			Pods:        pods,
+		}); err != nil {
+			return err
+		}
+	}
+	if o.traces.enabled() {
+		if err := o.traces.export(ctx, spans); err != nil {
+			return fmt.Errorf("failed to export traces: %w", err)
+		}
+	}
+	if o.push.enabled() {
+		if err := o.push.push(ctx, registry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printComparison prints a table per metric to stdout, with the median and
+// p95 of each step in a column per context.
+func printComparison(contexts []string, results []labelledResult) {
+	for _, m := range metricsOf(results) {
+		fmt.Println()
+		fmt.Println(m.label + ":")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
+		fmt.Fprintln(w, "step\tstat\t"+strings.Join(contexts, "\t"))
+		for _, label := range labelsOf(results) {
+			medians := make([]string, 0, len(contexts))
+			p95s := make([]string, 0, len(contexts))
+			for _, kubeContext := range contexts {
+				r, ok := findResult(results, label, kubeContext)
+				if !ok || !hasMetric(r.info, m.name) {
+					medians, p95s = append(medians, "-"), append(p95s, "-")
+					continue
+				}
+				data := durations(r.result.Stats, m.fn)
+				medians = append(medians, formatStat(statistic(statistics.Median(data))))
+				p95s = append(p95s, formatStat(statistic(statistics.Percentile(data, 95))))
+			}
+			fmt.Fprintf(w, "%s\tmedian\t%s\n", label, strings.Join(medians, "\t"))
+			fmt.Fprintf(w, "%s\tp95\t%s\n", label, strings.Join(p95s, "\t"))
+		}
+		w.Flush()
+	}
+}
+
+// comparison returns the results of all contexts side by side, if there are
+// several.
+func comparison(results []labelledResult) *report.Comparison {
+	contexts := contextsOf(results)
+	if len(contexts) < 2 {
+		return nil
+	}
+	c := &report.Comparison{Columns: contexts}
+	for _, m := range metricsOf(results) {
+		for _, label := range labelsOf(results) {
+			row := report.ComparisonRow{Label: m.label + ", " + label, Values: make([][]float64, len(contexts))}
+			for i, kubeContext := range contexts {
+				if r, ok := findResult(results, label, kubeContext); ok && hasMetric(r.info, m.name) {
+					row.Values[i] = durations(r.result.Stats, m.fn)
+				}
+			}
+			c.Rows = append(c.Rows, row)
+		}
+	}
+	return c
+}
+
+// labelsOf returns the distinct labels of all results, in order.
+func labelsOf(results []labelledResult) []string {
+	var labels []string
+	seen := make(map[string]bool)
+	for _, r := range results {
+		if !seen[r.label] {
+			seen[r.label] = true
+			labels = append(labels, r.label)
+		}
+	}
+	return labels
+}
+
+// contextsOf returns the distinct contexts of all results, in order.
+func contextsOf(results []labelledResult) []string {
+	var contexts []string
+	seen := make(map[string]bool)
+	for _, r := range results {
+		if r.context != "" && !seen[r.context] {
+			seen[r.context] = true
+			contexts = append(contexts, r.context)
+		}
+	}
+	return contexts
+}
+
+func findResult(results []labelledResult, label, kubeContext string) (labelledResult, bool) {
+	for _, r := range results {
+		if r.label == label && r.context == kubeContext {
+			return r, true
+		}
+	}
+	return labelledResult{}, false
+}
+
+// metricsOf returns the metrics of all results, in order of their first
+// appearance.
+func metricsOf(results []labelledResult) []metric {
+	var all []metric
+	seen := make(map[string]bool)
+	for _, r := range results {
+		for _, m := range metricsFor(r.info) {
+			if !seen[m.name] {
+				seen[m.name] = true
+				all = append(all, m)
+			}
+		}
+	}
+	return all
+}
+
+// hasMetric returns true if the metric of the given name is available for the
+// given run.
+func hasMetric(info runInfo, name string) bool {
+	for _, m := range metricsFor(info) {
+		if m.name == name {
+			return true
+		}
+	}
+	return false
+}
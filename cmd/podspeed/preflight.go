@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	podtemplate "github.com/markusthoemmes/podspeed/pkg/pod/template"
+	podtypes "github.com/markusthoemmes/podspeed/pkg/pod/types"
+	"github.com/markusthoemmes/podspeed/pkg/preflight"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+// commandPreflight checks whether the cluster is set up to run podspeed.
+const commandPreflight = "preflight"
+
+func runPreflight(args []string) {
+	var (
+		ns       string
+		typ      string
+		template string
+		podN     int
+		cluster  kubeFlags
+	)
+
+	supportedTypes, err := podtypes.Names()
+	if err != nil {
+		log.Fatalln("failed to built in types: ", err)
+	}
+
+	flags := newFlagSet(commandPreflight)
+	flags.StringVar(&ns, "n", "default", "the namespace to create the pods in")
+	flags.StringVar(&typ, "typ", "basic", "the type of pods to create, supported values: "+strings.Join(supportedTypes, ", "))
+	flags.StringVar(&template, "template", "", "a YAML template to create pods from, reads stdin if '-'")
+	flags.IntVar(&podN, "pods", 1, "the amount of pods to create")
+	cluster.register(flags)
+	flags.Parse(args)
+
+	podFn, err := podtypes.GetConstructor(typ)
+	if err != nil {
+		log.Fatalln("failed to load constructor, valid values for -typ are: ", supportedTypes, err)
+	}
+	opts := preflight.Options{Namespace: ns, PodFn: podFn, Pods: podN}
+	if template != "" {
+		t, err := loadTemplate(template)
+		if err != nil {
+			log.Fatalln("Failed to generate template from file", err)
+		}
+		opts.PodFn = t.PodConstructor("")
+		if t.HasCompanions() {
+			opts.Companions = func(scope podtemplate.Scope, ns, pod string) ([]runtime.Object, error) {
+				return t.Companions(scope, "", ns, pod)
+			}
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	_, kube := cluster.client()
+	if !runChecks(ctx, kube, opts) {
+		log.Fatalln("Some preflight checks failed")
+	}
+}
+
+// runChecks runs all preflight checks, prints their results and returns
+// whether all of them passed.
+func runChecks(ctx context.Context, kube kubernetes.Interface, opts preflight.Options) bool {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.Debug)
+	fmt.Fprintln(w, "check\tresult\tdetails")
+	failed := false
+	for _, check := range preflight.Checks(kube, opts) {
+		details, err := check.Run(ctx)
+		result := "ok"
+		if err != nil {
+			result, details, failed = "failed", err.Error(), true
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", check.Name, result, details)
+	}
+	w.Flush()
+	return !failed
+}
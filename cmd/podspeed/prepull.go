@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	podtypes "github.com/markusthoemmes/podspeed/pkg/pod/types"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientappsv1 "k8s.io/client-go/kubernetes/typed/apps/v1"
+)
+
+// commandPrepull pulls the images of the pods to all nodes.
+const commandPrepull = "prepull"
+
+// warmupLabel marks the DaemonSet that prepulls images.
+const warmupLabel = "podspeed/warmup"
+
+func runPrepull(args []string) {
+	var (
+		ns       string
+		typ      string
+		template string
+		cluster  kubeFlags
+	)
+
+	supportedTypes, err := podtypes.Names()
+	if err != nil {
+		log.Fatalln("failed to built in types: ", err)
+	}
+
+	flags := newFlagSet(commandPrepull)
+	flags.StringVar(&ns, "n", "default", "the namespace to create the DaemonSet pulling the images in")
+	flags.StringVar(&typ, "typ", "basic", "the type of pods to pull the images of, supported values: "+strings.Join(supportedTypes, ", "))
+	flags.StringVar(&template, "template", "", "a YAML template of the pods to pull the images of, reads stdin if '-'")
+	cluster.register(flags)
+	flags.Parse(args)
+
+	podFn, err := podtypes.GetConstructor(typ)
+	if err != nil {
+		log.Fatalln("failed to load constructor, valid values for -typ are: ", supportedTypes, err)
+	}
+	if template != "" {
+		t, err := loadTemplate(template)
+		if err != nil {
+			log.Fatalln("Failed to generate template from file", err)
+		}
+		podFn = t.PodConstructor("")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	_, kube := cluster.client()
+
+	log.Println("Prepulling images to all nodes")
+	if err := prepullImages(ctx, kube.AppsV1().DaemonSets(ns), podFn(ns, "").Spec); err != nil {
+		log.Fatalln("Failed to prepull images", err)
+	}
+	log.Println("Prepulling done")
+}
+
+func prepullImages(ctx context.Context, client clientappsv1.DaemonSetInterface, podSpec corev1.PodSpec) error {
+	labels := map[string]string{
+		warmupLabel: "true",
+	}
+	ds := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "warmup",
+			Labels: labels,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: podSpec,
+			},
+		},
+	}
+
+	if _, err := client.Create(ctx, ds, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create DaemonSet: %w", err)
+	}
+
+	if err := wait.PollImmediate(1*time.Second, 3*time.Minute, func() (bool, error) {
+		got, err := client.Get(ctx, ds.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, fmt.Errorf("failed to fetch DaemonSet: %w", err)
+		}
+		return got.Status.NumberReady > 0 && got.Status.NumberReady == got.Status.DesiredNumberScheduled, nil
+	}); err != nil {
+		return fmt.Errorf("DaemonSet never became ready: %w", err)
+	}
+
+	return client.Delete(ctx, ds.Name, metav1.DeleteOptions{})
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/benchmark"
+	"github.com/markusthoemmes/podspeed/pkg/metrics"
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+	statistics "github.com/montanaflynn/stats"
+)
+
+// pushJob is the job the results are pushed as.
+const pushJob = "podspeed"
+
+// pushOptions configures where the results of a run are pushed to.
+type pushOptions struct {
+	gateway     string
+	remoteWrite string
+	labels      metrics.Labels
+}
+
+func (p pushOptions) enabled() bool {
+	return p.gateway != "" || p.remoteWrite != ""
+}
+
+// push sends the registry to the configured Pushgateway and remote-write
+// endpoint.
+func (p pushOptions) push(ctx context.Context, r *metrics.Registry) error {
+	client := &http.Client{Timeout: 30 * time.Second}
+	if p.gateway != "" {
+		if err := metrics.Push(ctx, client, p.gateway, pushJob, p.labels, r); err != nil {
+			return fmt.Errorf("failed to push to pushgateway: %w", err)
+		}
+	}
+	if p.remoteWrite != "" {
+		labels := metrics.Labels{"job": pushJob}
+		for k, v := range p.labels {
+			labels[k] = v
+		}
+		if err := metrics.RemoteWrite(ctx, client, p.remoteWrite, labels, r, time.Now()); err != nil {
+			return fmt.Errorf("failed to push to remote-write endpoint: %w", err)
+		}
+	}
+	return nil
+}
+
+// recordResult records the result of a run in the registry, with the given
+// labels added to all metrics.
+func recordResult(r *metrics.Registry, labels metrics.Labels, result *benchmark.Result, ms []metric) {
+	for _, m := range ms {
+		phase := withLabel(labels, "phase", m.name)
+		data := make([]float64, 0, len(result.Stats))
+		for _, stat := range result.Stats {
+			d := m.fn(*stat)
+			if d == pod.Unknown {
+				continue
+			}
+			data = append(data, d.Seconds())
+			r.Observe("podspeed_pod_phase_duration_seconds", "The time it took pods to reach each phase of their startup.",
+				metrics.DefaultBuckets, phase, d.Seconds())
+		}
+		if len(data) == 0 {
+			continue
+		}
+
+		min, _ := statistics.Min(data)
+		max, _ := statistics.Max(data)
+		mean, _ := statistics.Mean(data)
+		for stat, value := range map[string]float64{"min": min, "max": max, "mean": mean} {
+			r.Set("podspeed_run_phase_seconds", "Summary statistics of the time it took pods to reach each phase of their startup.",
+				withLabel(phase, "stat", stat), value)
+		}
+		for _, p := range []float64{50, 95, 99} {
+			value, _ := statistics.Percentile(data, p)
+			r.Set("podspeed_run_phase_seconds", "Summary statistics of the time it took pods to reach each phase of their startup.",
+				withLabel(phase, "stat", fmt.Sprintf("p%g", p)), value)
+		}
+	}
+
+	r.Set("podspeed_run_pods", "The amount of pods created in the run.", labels, float64(len(result.Stats)))
+	if result.TimeToAvailable != 0 {
+		r.Set("podspeed_run_time_to_available_seconds", "The time from creating the workload until all of its pods were ready.",
+			labels, result.TimeToAvailable.Seconds())
+	}
+	if result.TimeToCompleted != 0 {
+		r.Set("podspeed_run_time_to_completed_seconds", "The time from creating the job until it completed.",
+			labels, result.TimeToCompleted.Seconds())
+	}
+	r.Set("podspeed_run_timestamp_seconds", "The time the run finished.", labels, float64(time.Now().Unix()))
+}
+
+// recordMetadata records what the results were produced with in the registry,
+// as a series per cluster that is always 1.
+func recordMetadata(r *metrics.Registry, m *runMetadata) {
+	for _, c := range m.Clusters {
+		labels := metrics.Labels{
+			"podspeed_version": m.Podspeed,
+			"template_sha256":  m.Template.SHA256,
+			"server_version":   c.ServerVersion,
+		}
+		if c.Context != "" {
+			labels["context"] = c.Context
+		}
+		r.Set("podspeed_run_info", "Describes the environment of the run, always 1.", labels, 1)
+	}
+}
+
+// withLabel returns a copy of the labels with the given label added.
+func withLabel(labels metrics.Labels, name, value string) metrics.Labels {
+	copied := make(metrics.Labels, len(labels)+1)
+	for k, v := range labels {
+		copied[k] = v
+	}
+	copied[name] = value
+	return copied
+}
+
+// labelsFlag collects repeated 'name=value' flags.
+type labelsFlag metrics.Labels
+
+func (l labelsFlag) String() string {
+	pairs := make([]string, 0, len(l))
+	for k, v := range l {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (l labelsFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("label %q must be of the form 'name=value'", value)
+	}
+	l[parts[0]] = parts[1]
+	return nil
+}
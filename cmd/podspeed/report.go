@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+	"github.com/markusthoemmes/podspeed/pkg/report"
+	"github.com/markusthoemmes/podspeed/pkg/trace"
+)
+
+// reportPods pairs the span trees of the pods, as built by podSpans, with
+// their stats.
+func reportPods(spans []trace.Span, stats map[string]*pod.Stats) []report.Pod {
+	out := make([]report.Pod, 0, len(spans))
+	for _, span := range spans {
+		out = append(out, report.Pod{Span: span, Stats: *stats[span.Attributes["k8s.pod.name"]]})
+	}
+	return out
+}
+
+// reportMetrics returns the given metrics of all stats, with the suffix
+// appended to their label.
+func reportMetrics(stats map[string]*pod.Stats, ms []metric, suffix string) []report.Metric {
+	out := make([]report.Metric, 0, len(ms))
+	for _, m := range ms {
+		out = append(out, report.Metric{Label: m.label + suffix, Values: durations(stats, m.fn)})
+	}
+	return out
+}
+
+// writeReport writes the HTML report to the given path.
+func writeReport(path string, r report.Report) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+	defer file.Close()
+	if err := report.Write(file, r); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+	statistics "github.com/montanaflynn/stats"
+)
+
+// resultsFile is the JSON encoding of the results of one or more runs.
+type resultsFile struct {
+	Metadata *runMetadata `json:"metadata,omitempty"`
+	Runs     []resultsRun `json:"runs"`
+}
+
+// resultsRun holds the results of a single run. Durations are in ms.
+type resultsRun struct {
+	Label           string          `json:"label,omitempty"`
+	Context         string          `json:"context,omitempty"`
+	Info            runInfo         `json:"info"`
+	TimeToAvailable float64         `json:"timeToAvailable,omitempty"`
+	TimeToCompleted float64         `json:"timeToCompleted,omitempty"`
+	Metrics         []resultsMetric `json:"metrics"`
+	// Namespaces summarizes the metrics per namespace, if the pods were spread
+	// across several.
+	Namespaces map[string][]resultsMetric `json:"namespaces,omitempty"`
+	Pods       map[string]*pod.Stats      `json:"pods"`
+}
+
+// resultsMetric summarizes a metric over all pods of a run. Statistics that
+// can't be computed, i.e. percentiles of too few pods, are null.
+type resultsMetric struct {
+	Name   string   `json:"name"`
+	Label  string   `json:"label"`
+	Min    *float64 `json:"min"`
+	Max    *float64 `json:"max"`
+	Mean   *float64 `json:"mean"`
+	Median *float64 `json:"median"`
+	P25    *float64 `json:"p25"`
+	P75    *float64 `json:"p75"`
+	P95    *float64 `json:"p95"`
+	P99    *float64 `json:"p99"`
+}
+
+// summarizeMetrics summarizes all metrics of the run over the given pods.
+func summarizeMetrics(info runInfo, stats map[string]*pod.Stats) []resultsMetric {
+	var metrics []resultsMetric
+	for _, m := range metricsFor(info) {
+		data := durations(stats, m.fn)
+		metrics = append(metrics, resultsMetric{
+			Name:   m.name,
+			Label:  m.label,
+			Min:    statistic(statistics.Min(data)),
+			Max:    statistic(statistics.Max(data)),
+			Mean:   statistic(statistics.Mean(data)),
+			Median: statistic(statistics.Median(data)),
+			P25:    statistic(statistics.Percentile(data, 25)),
+			P75:    statistic(statistics.Percentile(data, 75)),
+			P95:    statistic(statistics.Percentile(data, 95)),
+			P99:    statistic(statistics.Percentile(data, 99)),
+		})
+	}
+	return metrics
+}
+
+// writeResults writes the given results as JSON to the given path.
+func writeResults(path string, metadata *runMetadata, results []labelledResult) error {
+	file := resultsFile{
+		Metadata: metadata,
+		Runs:     make([]resultsRun, 0, len(results)),
+	}
+	for _, r := range results {
+		run := resultsRun{
+			Label:           r.label,
+			Context:         r.context,
+			Info:            r.info,
+			TimeToAvailable: float64(r.result.TimeToAvailable) / float64(time.Millisecond),
+			TimeToCompleted: float64(r.result.TimeToCompleted) / float64(time.Millisecond),
+			Pods:            r.result.Stats,
+		}
+		run.Metrics = summarizeMetrics(r.info, r.result.Stats)
+		if len(r.info.Namespaces) > 1 {
+			run.Namespaces = make(map[string][]resultsMetric)
+			for ns, stats := range byNamespace(r.result.Stats) {
+				run.Namespaces[ns] = summarizeMetrics(r.info, stats)
+			}
+		}
+		file.Runs = append(file.Runs, run)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create results file: %w", err)
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(file); err != nil {
+		return fmt.Errorf("failed to write results: %w", err)
+	}
+	return nil
+}
+
+// name identifies the run among all others.
+func (r resultsRun) name() string {
+	if r.Context == "" {
+		return r.Label
+	}
+	return r.Label + " @ " + r.Context
+}
+
+// readResults reads the results written by writeResults.
+func readResults(path string) (resultsFile, error) {
+	var file resultsFile
+	f, err := os.Open(path)
+	if err != nil {
+		return file, fmt.Errorf("failed to open results file: %w", err)
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(&file); err != nil {
+		return file, fmt.Errorf("failed to decode results file %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// statistic returns a pointer to the given statistic, or nil if it couldn't be
+// computed.
+func statistic(v float64, err error) *float64 {
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return &v
+}
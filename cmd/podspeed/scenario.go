@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/google/uuid"
+	"github.com/markusthoemmes/podspeed/pkg/apicalls"
+	"github.com/markusthoemmes/podspeed/pkg/benchmark"
+	"github.com/markusthoemmes/podspeed/pkg/metrics"
+	"github.com/markusthoemmes/podspeed/pkg/namespace"
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+	podtemplate "github.com/markusthoemmes/podspeed/pkg/pod/template"
+	podtypes "github.com/markusthoemmes/podspeed/pkg/pod/types"
+	"github.com/markusthoemmes/podspeed/pkg/preflight"
+	"github.com/markusthoemmes/podspeed/pkg/scenario"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+// scenarioPods are the pods a scenario creates.
+type scenarioPods struct {
+	prefix     string
+	podFn      func(string, string) *corev1.Pod
+	companions func(podtemplate.Scope, string, string) ([]runtime.Object, error)
+}
+
+// runScenario runs the steps of the scenario at the given path. If contexts
+// are given, the scenario is run against each of them and the results are
+// compared. With preflightChecks, all clusters are checked before any run.
+func runScenario(path string, progress bool, cluster kubeFlags, contexts []string, parallel, preflightChecks bool) {
+	s, err := scenario.Load(path)
+	if err != nil {
+		log.Fatalln("Failed to load scenario", err)
+	}
+
+	pods := scenarioPods{prefix: s.Pod.Type}
+	var templateContent []byte
+	if s.Pod.Template != "" {
+		templateContent, err = readTemplate(s.Pod.Template)
+		if err != nil {
+			log.Fatalln("Failed to read template", err)
+		}
+		t, err := podtemplate.Parse(bytes.NewReader(templateContent))
+		if err != nil {
+			log.Fatalln("Failed to generate template from file", err)
+		}
+		// Keep the ID short as it might end up in names with tight length limits.
+		id := uuid.NewString()[:8]
+		pods.podFn = t.PodConstructor(id)
+		pods.prefix = "template"
+		if t.HasCompanions() {
+			pods.companions = func(scope podtemplate.Scope, ns, pod string) ([]runtime.Object, error) {
+				return t.Companions(scope, id, ns, pod)
+			}
+		}
+	} else {
+		pods.podFn, err = podtypes.GetConstructor(s.Pod.Type)
+		if err != nil {
+			log.Fatalln("Failed to load constructor", err)
+		}
+	}
+	pods.podFn = s.Nodes.PodFn(pods.podFn)
+
+	templateName := s.Pod.Type
+	if s.Pod.Template != "" {
+		templateName = s.Pod.Template
+	}
+	metadata, err := newMetadata(templateName, templateContent, s)
+	if err != nil {
+		log.Fatalln("Failed to describe the run", err)
+	}
+
+	out := outputs{
+		metadata: metadata,
+		details:  s.Outputs.Details,
+		report:   s.Outputs.Report,
+		results:  s.Outputs.Results,
+		traces: traceOptions{
+			endpoint: s.Outputs.OTLP.Endpoint,
+			file:     s.Outputs.OTLP.File,
+			chrome:   s.Outputs.Trace,
+		},
+		push: pushOptions{
+			gateway:     s.Outputs.Pushgateway,
+			remoteWrite: s.Outputs.RemoteWrite,
+			labels:      metrics.Labels{"scenario": s.Name, "template": s.Pod.Type},
+		},
+	}
+	if s.Pod.Template != "" {
+		out.push.labels["template"] = filepath.Base(s.Pod.Template)
+	}
+	for k, v := range s.Outputs.Labels {
+		out.push.labels[k] = v
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	// The scenario runs against all contexts, or the one selected by the
+	// cluster flags if none are given.
+	targets := []scenarioTarget{{}}
+	if len(contexts) > 0 {
+		targets = make([]scenarioTarget, len(contexts))
+		for i, kubeContext := range contexts {
+			targets[i].context = kubeContext
+		}
+	}
+	for i := range targets {
+		t := &targets[i]
+		contextCluster := cluster
+		if t.context != "" {
+			contextCluster.context = t.context
+		}
+		_, t.kube, t.calls = contextCluster.recordedClient()
+		t.scenario = s
+	}
+
+	// The namespaces created for the scenario are deleted whatever its
+	// outcome, unless a step keeps its pods.
+	skipDelete := false
+	for _, step := range s.Steps {
+		skipDelete = skipDelete || step.SkipDelete
+	}
+	cleanup := func() error {
+		var firstErr error
+		for _, t := range targets {
+			if len(t.created) == 0 {
+				continue
+			}
+			if skipDelete {
+				log.Printf("Keeping namespaces %s%s", strings.Join(t.created, ", "), against(t.context))
+				continue
+			}
+			if err := deleteNamespaces(t.kube, t.created); err != nil {
+				log.Println("Failed to delete namespace", err)
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+		return firstErr
+	}
+	fail := func(v ...interface{}) {
+		cleanup()
+		log.Fatalln(v...)
+	}
+	for i := range targets {
+		t := &targets[i]
+		var first string
+		switch {
+		case s.CreateNamespace:
+			ns, err := namespace.Create(ctx, t.kube, s.NamespaceLabels)
+			if err != nil {
+				fail("Failed to create namespace", err)
+			}
+			log.Printf("Created namespace %s%s", ns, against(t.context))
+			t.created, first = []string{ns}, ns
+		case s.Namespaces > 0:
+			created, err := createNamespaces(ctx, t.kube, s.Namespaces, s.NamespaceLabels)
+			if err != nil {
+				fail(fmt.Sprintf("Failed to create namespaces%s:", against(t.context)), err)
+			}
+			t.created, t.namespaces, first = created, created, created[0]
+		case s.NamespaceSelector != "":
+			selected, err := selectNamespaces(ctx, t.kube, s.NamespaceSelector)
+			if err != nil {
+				fail(fmt.Sprintf("Failed to select namespaces%s:", against(t.context)), err)
+			}
+			log.Printf("Spreading pods across %d namespaces%s", len(selected), against(t.context))
+			t.namespaces, first = selected, selected[0]
+		default:
+			continue
+		}
+		// Everything not spread across the namespaces goes to the first one.
+		inNamespace := *s
+		inNamespace.Namespace = first
+		t.scenario = &inNamespace
+	}
+
+	if preflightChecks {
+		// The largest step decides whether the scenario fits, all steps
+		// together what it needs to be allowed to do.
+		opts := preflight.Options{PodFn: pods.podFn, Prepull: s.Prepull, Events: out.events(), Companions: pods.companions}
+		for _, step := range s.Steps {
+			if step.Pods > opts.Pods {
+				opts.Pods = step.Pods
+			}
+			opts.Workloads = append(opts.Workloads, step.Workload)
+			opts.Endpoints = opts.Endpoints || step.Probe.Endpoints
+			opts.ServiceProbe = opts.ServiceProbe || step.Probe.Service != ""
+		}
+		for _, t := range targets {
+			opts.Namespace, opts.Namespaces = t.scenario.Namespace, t.namespaces
+			if t.context != "" {
+				fmt.Printf("Preflight checks against %s:\n", t.context)
+			}
+			if !runChecks(ctx, t.kube, opts) {
+				fail(fmt.Sprintf("Some preflight checks%s failed", against(t.context)))
+			}
+		}
+		fmt.Println()
+	}
+
+	if len(contexts) == 0 {
+		t := targets[0]
+		metadata.Clusters = []clusterMetadata{collectCluster(ctx, t.kube, cluster.context)}
+		results, err := executeScenario(ctx, t.kube, t.scenario, t.namespaces, pods, progress, out.events(), "")
+		if err != nil {
+			fail("Failed to run scenario", err)
+		}
+
+		fmt.Printf("Ran %d steps of scenario %s, results are in ms:\n", len(results), s.Name)
+		printResults("step", results)
+		printCalls(t.calls, "")
+		printScenarioDetails(out, results)
+		if err := out.exportAll(ctx, "podspeed: "+s.Name, "step", results); err != nil {
+			fail("Failed to export results", err)
+		}
+		if err := cleanup(); err != nil {
+			log.Fatalln("Failed to delete namespace", err)
+		}
+		return
+	}
+
+	if parallel {
+		// The progress of several runs can't be rendered at once.
+		progress = false
+	}
+	perContext := make([][]labelledResult, len(contexts))
+	errs := make([]error, len(contexts))
+	metadata.Clusters = make([]clusterMetadata, len(contexts))
+	run := func(i int) {
+		t := targets[i]
+		metadata.Clusters[i] = collectCluster(ctx, t.kube, t.context)
+		perContext[i], errs[i] = executeScenario(ctx, t.kube, t.scenario, t.namespaces, pods, progress, out.events(), t.context)
+	}
+	if parallel {
+		var wg sync.WaitGroup
+		for i := range contexts {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				run(i)
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		for i := range contexts {
+			if run(i); errs[i] != nil {
+				break
+			}
+		}
+	}
+	var results []labelledResult
+	for i, err := range errs {
+		if err != nil {
+			fail(fmt.Sprintf("Failed to run scenario against %s:", contexts[i]), err)
+		}
+		results = append(results, perContext[i]...)
+	}
+
+	fmt.Printf("Ran scenario %s against %s, results are in ms:\n", s.Name, strings.Join(contexts, ", "))
+	printComparison(contexts, results)
+	for _, t := range targets {
+		printCalls(t.calls, t.context)
+	}
+	printScenarioDetails(out, results)
+	if err := out.exportAll(ctx, "podspeed: "+s.Name+" on "+strings.Join(contexts, ", "), "step", results); err != nil {
+		fail("Failed to export results", err)
+	}
+	if err := cleanup(); err != nil {
+		log.Fatalln("Failed to delete namespace", err)
+	}
+}
+
+// scenarioTarget is a cluster a scenario runs against.
+type scenarioTarget struct {
+	// context is only set if several clusters are compared.
+	context string
+	kube    *kubernetes.Clientset
+	calls   *apicalls.Recorder
+	// scenario is the scenario to run, in the namespace created for it if it
+	// asks for one.
+	scenario *scenario.Scenario
+	// created are the namespaces created for the scenario, to delete them
+	// afterwards.
+	created []string
+	// namespaces are the namespaces to spread the pods across, if any.
+	namespaces []string
+}
+
+// against describes the given context for log messages, if set.
+func against(kubeContext string) string {
+	if kubeContext == "" {
+		return ""
+	}
+	return " against " + kubeContext
+}
+
+// executeScenario runs all steps of the scenario against the given cluster,
+// spreading the pods across the given namespaces if any. The results are
+// labelled by step and the given context.
+func executeScenario(ctx context.Context, kube kubernetes.Interface, s *scenario.Scenario, namespaces []string, pods scenarioPods, progress, events bool, kubeContext string) ([]labelledResult, error) {
+	if s.Prepull {
+		log.Printf("Prepulling images to all nodes%s", against(kubeContext))
+		if err := prepullImages(ctx, kube.AppsV1().DaemonSets(s.Namespace), pods.podFn(s.Namespace, "").Spec); err != nil {
+			return nil, fmt.Errorf("failed to prepull images: %w", err)
+		}
+		log.Printf("Prepulling done%s", against(kubeContext))
+	}
+
+	results := make([]labelledResult, 0, s.Repetitions*len(s.Steps))
+	for rep := 1; rep <= s.Repetitions; rep++ {
+		for _, step := range s.Steps {
+			label := step.Name
+			if s.Repetitions > 1 {
+				label = fmt.Sprintf("%s #%d", step.Name, rep)
+			}
+			log.Printf("Running step %s%s", label, against(kubeContext))
+
+			opts := benchmark.Options{
+				Namespace:    s.Namespace,
+				Namespaces:   namespaces,
+				Prefix:       pods.prefix,
+				PodFn:        pods.podFn,
+				Pods:         step.Pods,
+				Workload:     step.Workload,
+				SkipDelete:   step.SkipDelete,
+				DryRun:       step.DryRun,
+				Probe:        step.Probe.IP,
+				Endpoints:    step.Probe.Endpoints,
+				ServiceProbe: step.Probe.Service,
+				Timestamps:   step.Probe.Timestamps,
+				Companions:   pods.companions,
+				Events:       events,
+			}
+			stopProgress := func() {}
+			if progress {
+				opts.Tracker = pod.NewTracker()
+				stopProgress = showProgress(ctx, opts.Tracker, step.Pods)
+			}
+			result, err := benchmark.Run(ctx, kube, opts)
+			stopProgress()
+			if err != nil {
+				return nil, fmt.Errorf("failed to run step %s: %w", label, err)
+			}
+			results = append(results, labelledResult{
+				label:   label,
+				context: kubeContext,
+				info:    infoFor(pods.prefix, opts),
+				result:  result,
+			})
+		}
+	}
+	return results, nil
+}
+
+func printScenarioDetails(out outputs, results []labelledResult) {
+	if !out.details {
+		return
+	}
+	for _, r := range results {
+		fmt.Println()
+		if r.context != "" {
+			fmt.Printf("Step %s on %s:\n", r.label, r.context)
+		} else {
+			fmt.Printf("Step %s:\n", r.label)
+		}
+		printDetails(r.result)
+	}
+}
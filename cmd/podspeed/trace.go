@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+	"github.com/markusthoemmes/podspeed/pkg/trace"
+)
+
+// traceOptions configures where the traces of the pods are exported to.
+type traceOptions struct {
+	endpoint string
+	file     string
+	// chrome is the file to write the whole run in the Chrome Trace Event
+	// format to.
+	chrome string
+}
+
+func (t traceOptions) enabled() bool {
+	return t.endpoint != "" || t.file != "" || t.chrome != ""
+}
+
+// export writes the span trees to the configured files and sends them to the
+// configured endpoint.
+func (t traceOptions) export(ctx context.Context, spans []trace.Span) error {
+	if t.chrome != "" {
+		if err := writeTraceFile(t.chrome, spans, trace.EncodeChrome); err != nil {
+			return err
+		}
+	}
+	if t.file != "" {
+		if err := writeTraceFile(t.file, spans, trace.Encode); err != nil {
+			return err
+		}
+	}
+	if t.endpoint != "" {
+		client := &http.Client{Timeout: 30 * time.Second}
+		if err := trace.Export(ctx, client, t.endpoint, spans); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTraceFile writes the span trees to the file at the given path with the
+// given encoding.
+func writeTraceFile(path string, spans []trace.Span, encode func(io.Writer, []trace.Span) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create trace file: %w", err)
+	}
+	if err := encode(file, spans); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write trace file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write trace file: %w", err)
+	}
+	return nil
+}
+
+// podSpans builds the span trees of all pods, ordered by their creation. ns is
+// the namespace of pods that weren't seen in one.
+func podSpans(ns string, stats map[string]*pod.Stats, attributes map[string]string) []trace.Span {
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return stats[names[i]].Created.Before(stats[names[j]].Created)
+	})
+
+	spans := make([]trace.Span, 0, len(names))
+	for _, name := range names {
+		namespace := ns
+		if stats[name].Namespace != "" {
+			namespace = stats[name].Namespace
+		}
+		spans = append(spans, trace.PodSpan(namespace, name, *stats[name], attributes))
+	}
+	return spans
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	podtypes "github.com/markusthoemmes/podspeed/pkg/pod/types"
+)
+
+// commandTypes lists and shows the built-in pod types.
+const commandTypes = "types"
+
+func runTypes(args []string) {
+	flags := newFlagSet(commandTypes)
+	flags.Parse(args)
+
+	switch {
+	case flags.NArg() == 1 && flags.Arg(0) == "list":
+		names, err := podtypes.Names()
+		if err != nil {
+			log.Fatalln("Failed to list types", err)
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+	case flags.NArg() == 2 && flags.Arg(0) == "show":
+		// The template can be used as a starting point for -template.
+		manifest, err := podtypes.Manifest(flags.Arg(1))
+		if err != nil {
+			log.Fatalln("Failed to show type, list all types via 'podspeed types list'", err)
+		}
+		os.Stdout.Write(manifest)
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+)
+
+// commandVersion prints the version of podspeed.
+const commandVersion = "version"
+
+// version is set at build time via -ldflags '-X main.version=v1.2.3'.
+var version string
+
+func runVersion(args []string) {
+	flags := newFlagSet(commandVersion)
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	fmt.Printf("podspeed %s (%s)\n", podspeedVersion(), runtime.Version())
+}
+
+// podspeedVersion returns the version set at build time, or the module
+// version or VCS revision podspeed was built from.
+func podspeedVersion() string {
+	if version != "" {
+		return version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+
+	var revision, modified string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value
+		}
+	}
+	if revision == "" {
+		return "devel"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified == "true" {
+		revision += "-dirty"
+	}
+	return revision
+}
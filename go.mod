@@ -3,9 +3,13 @@ module github.com/markusthoemmes/podspeed
 go 1.16
 
 require (
+	github.com/golang/snappy v0.0.4
 	github.com/google/uuid v1.2.0
 	github.com/montanaflynn/stats v0.6.5
+	golang.org/x/term v0.0.0-20210220032956-6a3ed077a48d
+	google.golang.org/protobuf v1.26.0
 	k8s.io/api v0.22.0
 	k8s.io/apimachinery v0.22.0
 	k8s.io/client-go v0.22.0
+	sigs.k8s.io/yaml v1.2.0
 )
@@ -0,0 +1,248 @@
+package apicalls
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// throttledAfter is how long a request has to wait for the rate limiter to
+// count as throttled. Taking an available token takes a lot less.
+const throttledAfter = time.Millisecond
+
+// Key identifies a kind of API call.
+type Key struct {
+	// Verb is the Kubernetes verb of the call, i.e. "create" or "watch".
+	Verb string
+	// Resource is the resource the call is about, i.e. "pods" or
+	// "deployments.apps/scale", or the path for non-resource calls.
+	Resource string
+}
+
+// Calls are all API calls of a Key.
+type Calls struct {
+	Key
+	// Latencies are the times until the response headers were received.
+	Latencies []time.Duration
+	// Statuses counts the HTTP status codes of the responses, or "error" for
+	// requests that failed without a response.
+	Statuses map[string]int
+}
+
+// Throttling describes the time spent waiting for the client-side rate
+// limiter.
+type Throttling struct {
+	QPS   float32
+	Burst int
+	// Requests is the amount of requests that passed the rate limiter.
+	Requests int
+	// Throttled is the amount of requests that had to wait for it.
+	Throttled int
+	// Waited is the total time spent waiting.
+	Waited time.Duration
+	// MaxWait is the longest time a single request waited.
+	MaxWait time.Duration
+}
+
+// Recorder records all API calls made through the configs it instruments.
+type Recorder struct {
+	mu         sync.Mutex
+	calls      map[Key]*Calls
+	throttling Throttling
+}
+
+// NewRecorder returns an empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{calls: make(map[Key]*Calls)}
+}
+
+// Instrument makes the given config record all API calls and the time they
+// wait for its rate limiter, which is created from its QPS and Burst.
+func (r *Recorder) Instrument(config *rest.Config) {
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &transport{next: rt, recorder: r}
+	})
+
+	qps, burst := config.QPS, config.Burst
+	if qps == 0 {
+		qps = rest.DefaultQPS
+	}
+	if burst == 0 {
+		burst = rest.DefaultBurst
+	}
+	r.mu.Lock()
+	r.throttling.QPS, r.throttling.Burst = qps, burst
+	r.mu.Unlock()
+	config.RateLimiter = &rateLimiter{RateLimiter: flowcontrol.NewTokenBucketRateLimiter(qps, burst), recorder: r}
+}
+
+// Calls returns all recorded calls, sorted by resource and verb.
+func (r *Recorder) Calls() []Calls {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	calls := make([]Calls, 0, len(r.calls))
+	for _, c := range r.calls {
+		statuses := make(map[string]int, len(c.Statuses))
+		for status, n := range c.Statuses {
+			statuses[status] = n
+		}
+		calls = append(calls, Calls{
+			Key:       c.Key,
+			Latencies: append([]time.Duration(nil), c.Latencies...),
+			Statuses:  statuses,
+		})
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		if calls[i].Resource != calls[j].Resource {
+			return calls[i].Resource < calls[j].Resource
+		}
+		return calls[i].Verb < calls[j].Verb
+	})
+	return calls
+}
+
+// Throttling returns the time spent waiting for the rate limiter so far.
+func (r *Recorder) Throttling() Throttling {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.throttling
+}
+
+func (r *Recorder) record(key Key, latency time.Duration, status string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := r.calls[key]
+	if c == nil {
+		c = &Calls{Key: key, Statuses: make(map[string]int)}
+		r.calls[key] = c
+	}
+	c.Latencies = append(c.Latencies, latency)
+	c.Statuses[status]++
+}
+
+func (r *Recorder) recordWait(wait time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.throttling.Requests++
+	if wait >= throttledAfter {
+		r.throttling.Throttled++
+	}
+	r.throttling.Waited += wait
+	if wait > r.throttling.MaxWait {
+		r.throttling.MaxWait = wait
+	}
+}
+
+type transport struct {
+	next     http.RoundTripper
+	recorder *Recorder
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	latency := time.Since(start)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	t.recorder.record(keyOf(req), latency, status)
+	return resp, err
+}
+
+// rateLimiter times how long requests wait for the wrapped RateLimiter.
+type rateLimiter struct {
+	flowcontrol.RateLimiter
+	recorder *Recorder
+}
+
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	start := time.Now()
+	err := l.RateLimiter.Wait(ctx)
+	l.recorder.recordWait(time.Since(start))
+	return err
+}
+
+func (l *rateLimiter) Accept() {
+	start := time.Now()
+	l.RateLimiter.Accept()
+	l.recorder.recordWait(time.Since(start))
+}
+
+// keyOf derives the Key of a request from its method and path, like the API
+// server does.
+func keyOf(req *http.Request) Key {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	var group string
+	switch {
+	case len(parts) >= 3 && parts[0] == "api":
+		parts = parts[2:]
+	case len(parts) >= 4 && parts[0] == "apis":
+		group, parts = parts[1], parts[3:]
+	default:
+		// Discovery and other non-resource calls.
+		return Key{Verb: strings.ToLower(req.Method), Resource: req.URL.Path}
+	}
+
+	watch := req.URL.Query().Get("watch") == "true" || req.URL.Query().Get("watch") == "1"
+	if parts[0] == "watch" && len(parts) > 1 {
+		// The deprecated form of watches.
+		watch, parts = true, parts[1:]
+	}
+	if parts[0] == "namespaces" && (len(parts) > 3 || len(parts) == 3 && parts[2] != "status" && parts[2] != "finalize") {
+		// Drop the namespace of namespaced resources.
+		parts = parts[2:]
+	}
+
+	resource, name := parts[0], ""
+	if group != "" {
+		resource += "." + group
+	}
+	if len(parts) > 1 {
+		name = parts[1]
+	}
+	if len(parts) > 2 {
+		resource += "/" + parts[2]
+	}
+
+	var verb string
+	switch req.Method {
+	case http.MethodGet:
+		switch {
+		case watch:
+			verb = "watch"
+		case name == "":
+			verb = "list"
+		default:
+			verb = "get"
+		}
+	case http.MethodPost:
+		verb = "create"
+	case http.MethodPut:
+		verb = "update"
+	case http.MethodPatch:
+		verb = "patch"
+	case http.MethodDelete:
+		verb = "delete"
+		if name == "" {
+			verb = "deletecollection"
+		}
+	default:
+		verb = strings.ToLower(req.Method)
+	}
+	if req.URL.Query().Get("dryRun") != "" {
+		verb += " (dry-run)"
+	}
+	return Key{Verb: verb, Resource: resource}
+}
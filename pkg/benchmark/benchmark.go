@@ -0,0 +1,467 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/markusthoemmes/podspeed/pkg/callback"
+	"github.com/markusthoemmes/podspeed/pkg/companion"
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+	podtemplate "github.com/markusthoemmes/podspeed/pkg/pod/template"
+	"github.com/markusthoemmes/podspeed/pkg/record"
+	"github.com/markusthoemmes/podspeed/pkg/startup"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Options configures a single benchmark run.
+type Options struct {
+	// Namespace is the namespace to create the pods in.
+	Namespace string
+	// Namespaces, if set, spreads the pods across the given namespaces, one
+	// after the other, instead of creating them in Namespace. It's only
+	// supported for bare pods without Services.
+	Namespaces []string
+	// Prefix is prepended to the generated names of the pods.
+	Prefix string
+	// PodFn constructs the pods to create.
+	PodFn func(string, string) *corev1.Pod
+	// Pods is the amount of pods to create.
+	Pods int
+	// Workload is the kind of object to create. Defaults to bare pods.
+	Workload Workload
+	// SkipDelete keeps the pods around after they're ready if true.
+	SkipDelete bool
+	// DryRun submits each bare pod in dry-run mode right before creating it if
+	// true, to measure the time spent in admission separately.
+	DryRun bool
+	// Probe probes the pods as soon as they have an IP address if true.
+	Probe bool
+	// Endpoints creates a Service selecting the pods and waits for each pod to
+	// become a ready endpoint of it if true.
+	Endpoints bool
+	// Timestamps fetches the startup timestamps the application reports about
+	// itself from each pod once it's ready if true.
+	Timestamps bool
+	// CallbackURL, if set, is passed to the applications via
+	// startup.CallbackEnv for them to report to once they're listening.
+	CallbackURL string
+	// Callbacks, if set, receives the reports of the applications. A report
+	// of each pod is awaited once it's ready.
+	Callbacks <-chan callback.Callback
+	// ServiceProbe, if set, creates a Service selecting the pods and probes it
+	// until each pod served a request through it.
+	ServiceProbe ServiceProbe
+	// Events watches the Kubernetes Events of the pods and records the image
+	// pulls they report if true.
+	Events bool
+	// Tracker, if set, records the Stats of the pods. It allows observing the
+	// progress of the run.
+	Tracker *pod.Tracker
+	// Recorder, if set, records all watch events and Kubernetes Events of the
+	// pods, as well as their final Stats.
+	Recorder *record.Recorder
+	// Companions, if set, returns the companion objects of the given scope to
+	// create before the pods that need them. They are deleted along with the
+	// pods.
+	Companions func(scope podtemplate.Scope, ns, pod string) ([]runtime.Object, error)
+}
+
+// namespaces returns all namespaces the pods are created in.
+func (o Options) namespaces() []string {
+	if len(o.Namespaces) > 0 {
+		return o.Namespaces
+	}
+	return []string{o.Namespace}
+}
+
+// watchNamespace returns the namespace to watch the pods and their Events in,
+// which is all namespaces if they're spread across several.
+func (o Options) watchNamespace() string {
+	if namespaces := o.namespaces(); len(namespaces) == 1 {
+		return namespaces[0]
+	}
+	return metav1.NamespaceAll
+}
+
+// Result is the outcome of a benchmark run.
+type Result struct {
+	// Stats are the Stats of all pods, keyed by their name.
+	Stats map[string]*pod.Stats
+	// TimeToAvailable is the time from creating a workload until all of its
+	// pods are ready. It's zero for bare pods and Jobs.
+	TimeToAvailable time.Duration
+	// TimeToCompleted is the time from creating a Job until it completed. It's
+	// zero for all other workloads.
+	TimeToCompleted time.Duration
+}
+
+// Run runs a benchmark as configured by the given options and returns the
+// Stats gathered for all pods.
+func Run(ctx context.Context, kube kubernetes.Interface, opts Options) (result *Result, err error) {
+	if len(opts.Namespaces) > 0 && ((opts.Workload != "" && opts.Workload != WorkloadPod) || opts.Endpoints || opts.ServiceProbe != "") {
+		return nil, errors.New("spreading pods across namespaces is only supported for bare pods without Services")
+	}
+	if opts.CallbackURL != "" {
+		opts.PodFn = withEnv(opts.PodFn, startup.CallbackEnv, opts.CallbackURL)
+	}
+
+	runLabels := labels.Set{
+		pod.RunLabel: uuid.NewString(),
+	}
+	w, err := watchPods(ctx, kube, watchOptions{
+		namespace: opts.watchNamespace(),
+		selector:  runLabels.String(),
+		pods:      opts.Pods,
+		probe:     opts.Probe,
+		tracker:   opts.Tracker,
+		recorder:  opts.Recorder,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer w.Stop()
+
+	var svc *corev1.Service
+	if opts.Endpoints || opts.ServiceProbe != "" {
+		svc, err = createService(ctx, kube, opts, runLabels)
+		if err != nil {
+			return nil, err
+		}
+		if !opts.SkipDelete {
+			defer cleanup(&err, func(ctx context.Context) error {
+				if err := kube.CoreV1().Services(opts.Namespace).Delete(ctx, svc.Name, metav1.DeleteOptions{}); err != nil {
+					return fmt.Errorf("failed to delete service: %w", err)
+				}
+				return nil
+			})
+		}
+	}
+	if opts.Endpoints {
+		endpoints, err := watchEndpoints(ctx, kube, opts.Namespace, svc.Name, w)
+		if err != nil {
+			return nil, err
+		}
+		defer endpoints.Stop()
+	}
+	if opts.Events {
+		events, err := watchEvents(ctx, kube, opts.watchNamespace(), w, opts.Recorder)
+		if err != nil {
+			return nil, err
+		}
+		defer events.Stop()
+	}
+	if opts.Callbacks != nil {
+		callbackCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go receiveCallbacks(callbackCtx, opts.Callbacks, w)
+	}
+	if opts.ServiceProbe != "" {
+		probeCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go probeService(probeCtx, svc, opts.ServiceProbe, w)
+	}
+
+	for _, ns := range opts.namespaces() {
+		ns := ns
+		var objs []runtime.Object
+		objs, err = createCompanions(ctx, kube, opts, ns, podtemplate.ScopeRun, "", runLabels)
+		if err != nil {
+			return nil, err
+		}
+		if !opts.SkipDelete {
+			defer cleanup(&err, func(ctx context.Context) error {
+				return deleteCompanions(ctx, kube, ns, objs)
+			})
+		}
+	}
+
+	if opts.Workload == "" || opts.Workload == WorkloadPod {
+		result, err = runPods(ctx, kube, opts, runLabels, w)
+	} else {
+		result, err = runWorkload(ctx, kube, opts, runLabels, w)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if opts.Recorder != nil {
+		opts.Recorder.Stats(result.Stats)
+	}
+	return result, nil
+}
+
+// cleanupTimeout bounds deleting the objects created for a run.
+const cleanupTimeout = time.Minute
+
+// cleanup deletes objects created for a run via del when the run returns. It
+// doesn't use the context of the run, to clean up after a failed or
+// interrupted run as well. A failing delete fails an otherwise successful run.
+func cleanup(err *error, del func(context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+	if delErr := del(ctx); delErr != nil && *err == nil {
+		*err = delErr
+	}
+}
+
+// runPods creates the pods one after the other and waits for each of them to
+// become ready.
+func runPods(ctx context.Context, kube kubernetes.Interface, opts Options, runLabels labels.Set, w *podWatcher) (*Result, error) {
+	namespaces := opts.namespaces()
+	pods := make([]*corev1.Pod, 0, opts.Pods)
+	for i := 0; i < opts.Pods; i++ {
+		p := opts.PodFn(namespaces[i%len(namespaces)], opts.Prefix+"-"+uuid.NewString())
+		p.Labels = runLabels
+		pods = append(pods, p)
+	}
+
+	for _, p := range pods {
+		if err := runPod(ctx, kube, opts, p, runLabels, w); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Result{Stats: w.tracker.Stats()}, nil
+}
+
+// runPod creates the given pod, waits for it to become ready and deletes it
+// again, together with its companion objects, unless SkipDelete is set.
+func runPod(ctx context.Context, kube kubernetes.Interface, opts Options, p *corev1.Pod, runLabels labels.Set, w *podWatcher) (err error) {
+	companions, err := createCompanions(ctx, kube, opts, p.Namespace, podtemplate.ScopePod, p.Name, runLabels)
+	if err != nil {
+		return err
+	}
+	if !opts.SkipDelete {
+		defer cleanup(&err, func(ctx context.Context) error {
+			return deleteCompanions(ctx, kube, p.Namespace, companions)
+		})
+	}
+
+	if opts.DryRun {
+		started := time.Now()
+		if _, err := kube.CoreV1().Pods(p.Namespace).Create(ctx, p, metav1.CreateOptions{
+			DryRun: []string{metav1.DryRunAll},
+		}); err != nil {
+			return fmt.Errorf("failed to create pod in dry-run mode: %w", err)
+		}
+		finished := time.Now()
+		w.tracker.Update(p.Name, func(s *pod.Stats) {
+			s.DryRunStarted, s.DryRunFinished = started, finished
+		})
+	}
+
+	started := time.Now()
+	if _, err := kube.CoreV1().Pods(p.Namespace).Create(ctx, p, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed to create pod: %w", err)
+	}
+	finished := time.Now()
+	w.tracker.Update(p.Name, func(s *pod.Stats) {
+		s.CreateStarted, s.CreateFinished = started, finished
+	})
+
+	// Wait for the pod to become ready.
+	if err := waitForN(ctx, w.readyCh, 1); err != nil {
+		return fmt.Errorf("failed to wait for pod becoming ready: %w", err)
+	}
+	if opts.Probe {
+		// And for the pod to be probed, if we're doing that.
+		if err := waitForN(ctx, w.probedCh, 1); err != nil {
+			return fmt.Errorf("failed to wait for pod be probed: %w", err)
+		}
+	}
+	if opts.Endpoints {
+		// And for the pod to become an endpoint, if we're doing that.
+		if err := waitForN(ctx, w.endpointCh, 1); err != nil {
+			return fmt.Errorf("failed to wait for pod becoming an endpoint: %w", err)
+		}
+	}
+	if opts.ServiceProbe != "" {
+		// And for the pod to serve a request through the Service, if we're
+		// doing that.
+		if err := waitForN(ctx, w.serviceProbedCh, 1); err != nil {
+			return fmt.Errorf("failed to wait for pod be probed through the service: %w", err)
+		}
+	}
+	if opts.Callbacks != nil {
+		// And for the application to report back, if we're doing that.
+		if err := waitForN(ctx, w.callbackCh, 1); err != nil {
+			return fmt.Errorf("failed to wait for callback of pod: %w", err)
+		}
+	}
+	if opts.Timestamps {
+		if err := collectTimestamps(ctx, opts, w, p.Name); err != nil {
+			return err
+		}
+	}
+
+	if !opts.SkipDelete {
+		var zero int64
+		if err := kube.CoreV1().Pods(p.Namespace).Delete(ctx, p.Name, metav1.DeleteOptions{
+			GracePeriodSeconds: &zero,
+		}); err != nil {
+			return fmt.Errorf("failed to delete pod: %w", err)
+		}
+
+		if err := waitForN(ctx, w.deletedCh, 1); err != nil {
+			return fmt.Errorf("failed to wait for pod being deleted: %w", err)
+		}
+	}
+	return nil
+}
+
+// podWatcher tracks the pods of a run and signals their state transitions.
+type podWatcher struct {
+	watch.Interface
+
+	tracker    *pod.Tracker
+	readyCh    chan struct{}
+	deletedCh  chan struct{}
+	probedCh   chan struct{}
+	endpointCh chan struct{}
+	// serviceProbedCh signals pods that served a request through the Service.
+	serviceProbedCh chan struct{}
+	callbackCh      chan struct{}
+}
+
+// watchOptions configures which pods a podWatcher tracks.
+type watchOptions struct {
+	namespace string
+	selector  string
+	// resourceVersion to start watching from, if set.
+	resourceVersion string
+	// ignore contains the names of pods that are not tracked.
+	ignore sets.String
+	// pods is the amount of pods expected to be tracked.
+	pods  int
+	probe bool
+	// tracker to record the Stats with. A new one is created if nil.
+	tracker *pod.Tracker
+	// recorder records the watch events of the tracked pods, if set.
+	recorder *record.Recorder
+}
+
+func watchPods(ctx context.Context, kube kubernetes.Interface, opts watchOptions) (*podWatcher, error) {
+	watcher, err := kube.CoreV1().Pods(opts.namespace).Watch(ctx, metav1.ListOptions{
+		LabelSelector:   opts.selector,
+		ResourceVersion: opts.resourceVersion,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup watch for pods: %w", err)
+	}
+
+	tracker := opts.tracker
+	if tracker == nil {
+		tracker = pod.NewTracker()
+	}
+	w := &podWatcher{
+		Interface:  watcher,
+		tracker:    tracker,
+		readyCh:    make(chan struct{}, opts.pods),
+		deletedCh:  make(chan struct{}, opts.pods),
+		probedCh:   make(chan struct{}, opts.pods),
+		endpointCh: make(chan struct{}, opts.pods),
+
+		serviceProbedCh: make(chan struct{}, opts.pods),
+		callbackCh:      make(chan struct{}, opts.pods),
+	}
+	ipCh := make(chan *corev1.Pod, opts.pods)
+	go func() {
+		defer close(ipCh)
+		for event := range watcher.ResultChan() {
+			p, ok := event.Object.(*corev1.Pod)
+			if !ok || opts.ignore.Has(p.Name) {
+				continue
+			}
+			now := time.Now()
+			if opts.recorder != nil {
+				opts.recorder.Pod(event.Type, p, now)
+			}
+			trans := w.tracker.Observe(event.Type, p, now)
+			if trans.HasIP {
+				ipCh <- p
+			}
+			if trans.Ready {
+				w.readyCh <- struct{}{}
+			}
+			if trans.Deleted {
+				w.deletedCh <- struct{}{}
+			}
+		}
+	}()
+
+	if opts.probe {
+		go func() {
+			for p := range ipCh {
+				// TODO: Probe path needs to be adjustable per app.
+				url := "http://" + p.Status.PodIP + ":8012"
+				wait.PollImmediateUntil(10*time.Millisecond, func() (bool, error) {
+					resp, err := http.Get(url)
+					if err != nil {
+						return false, nil
+					}
+					defer resp.Body.Close()
+					return resp.StatusCode == http.StatusOK, nil
+				}, ctx.Done())
+				w.tracker.Update(p.Name, func(s *pod.Stats) {
+					s.Probed = time.Now()
+				})
+				w.probedCh <- struct{}{}
+			}
+		}()
+	}
+	return w, nil
+}
+
+func createCompanions(ctx context.Context, kube kubernetes.Interface, opts Options, ns string, scope podtemplate.Scope, podName string, labels map[string]string) ([]runtime.Object, error) {
+	if opts.Companions == nil {
+		return nil, nil
+	}
+	objs, err := opts.Companions(scope, ns, podName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate companion objects: %w", err)
+	}
+	for i, obj := range objs {
+		if err := companion.Create(ctx, kube, ns, labels, obj); err != nil {
+			// Don't leave the ones already created behind.
+			cleanup(&err, func(ctx context.Context) error {
+				return deleteCompanions(ctx, kube, ns, objs[:i])
+			})
+			return nil, err
+		}
+	}
+	return objs, nil
+}
+
+func deleteCompanions(ctx context.Context, kube kubernetes.Interface, ns string, objs []runtime.Object) error {
+	for _, obj := range objs {
+		if err := companion.Delete(ctx, kube, ns, obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func waitForN(ctx context.Context, ch chan struct{}, n int) error {
+	var seen int
+	for {
+		select {
+		case <-ch:
+			seen++
+			if seen == n {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
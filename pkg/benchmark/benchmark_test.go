@@ -0,0 +1,165 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	podtemplate "github.com/markusthoemmes/podspeed/pkg/pod/template"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestRunDryRun(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	kube := fake.NewSimpleClientset()
+	// The fake clientset doesn't see the dry-run option, so the first create
+	// of each pod is taken as the dry-run and not persisted.
+	var mu sync.Mutex
+	creates := make(map[string]int)
+	kube.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		p := action.(k8stesting.CreateAction).GetObject().(*corev1.Pod)
+		mu.Lock()
+		defer mu.Unlock()
+		creates[p.Name]++
+		return creates[p.Name] == 1, p, nil
+	})
+	startKubelet(ctx, t, kube)
+
+	opts := testOptions()
+	opts.Pods = 2
+	opts.DryRun = true
+	result, err := Run(ctx, kube, opts)
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	if len(result.Stats) != opts.Pods {
+		t.Fatalf("got stats of %d pods, want %d", len(result.Stats), opts.Pods)
+	}
+	for name, s := range result.Stats {
+		if creates[name] != 2 {
+			t.Errorf("pod %s was created %d times, want 2", name, creates[name])
+		}
+		if s.DryRunStarted.IsZero() || s.DryRunFinished.Before(s.DryRunStarted) ||
+			s.CreateStarted.Before(s.DryRunFinished) || s.CreateFinished.Before(s.CreateStarted) {
+			t.Errorf("stats of %s = %+v, want the dry-run before the create", name, s)
+		}
+	}
+}
+
+func TestRunCleansUpOnError(t *testing.T) {
+	tests := []struct {
+		name       string
+		skipDelete bool
+		want       int
+	}{{
+		name: "delete",
+		want: 0,
+	}, {
+		name:       "skip delete",
+		skipDelete: true,
+		want:       2,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			kube := fake.NewSimpleClientset()
+			kube.PrependReactor("create", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.New("no pods today")
+			})
+
+			opts := testOptions()
+			opts.SkipDelete = test.skipDelete
+			opts.Companions = func(scope podtemplate.Scope, ns, pod string) ([]runtime.Object, error) {
+				return []runtime.Object{&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: string(scope) + pod},
+				}}, nil
+			}
+			_, err := Run(ctx, kube, opts)
+			if err == nil {
+				t.Fatal("Run() = nil, want an error")
+			}
+
+			left, err := kube.CoreV1().ConfigMaps(testNamespace).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("failed to list configmaps: %v", err)
+			}
+			if len(left.Items) != test.want {
+				t.Errorf("%d companions left, want %d", len(left.Items), test.want)
+			}
+		})
+	}
+}
+
+func TestRunSpread(t *testing.T) {
+	tests := []struct {
+		name       string
+		namespaces []string
+		pods       int
+		want       map[string]int
+	}{{
+		name:       "round robin",
+		namespaces: []string{"a", "b"},
+		pods:       3,
+		want:       map[string]int{"a": 2, "b": 1},
+	}, {
+		name:       "single namespace",
+		namespaces: []string{"a"},
+		pods:       2,
+		want:       map[string]int{"a": 2},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			kube := fake.NewSimpleClientset()
+			startKubelet(ctx, t, kube)
+
+			opts := testOptions()
+			opts.Namespaces = test.namespaces
+			opts.Pods = test.pods
+			result, err := Run(ctx, kube, opts)
+			if err != nil {
+				t.Fatalf("Run() = %v", err)
+			}
+
+			got := make(map[string]int)
+			for name, s := range result.Stats {
+				if s.Ready.IsZero() {
+					t.Errorf("stats of %s = %+v, want ready", name, s)
+				}
+				got[s.Namespace]++
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("pods per namespace = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// startKubelet makes all pods in all namespaces ready right away.
+func startKubelet(ctx context.Context, t *testing.T, kube *fake.Clientset) {
+	pods, err := kube.CoreV1().Pods(metav1.NamespaceAll).Watch(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to watch pods: %v", err)
+	}
+	go func() {
+		<-ctx.Done()
+		pods.Stop()
+	}()
+	go readyPods(ctx, t, kube, pods)
+}
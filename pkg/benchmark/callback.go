@@ -0,0 +1,45 @@
+package benchmark
+
+import (
+	"context"
+
+	"github.com/markusthoemmes/podspeed/pkg/callback"
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// receiveCallbacks records the callbacks of the tracked pods until the context
+// is done.
+func receiveCallbacks(ctx context.Context, callbacks <-chan callback.Callback, w *podWatcher) {
+	for {
+		select {
+		case c := <-callbacks:
+			// Ignore stragglers of previous runs.
+			if !w.tracker.Has(c.Pod) {
+				continue
+			}
+			w.tracker.Update(c.Pod, func(s *pod.Stats) {
+				s.CallbackReceived = c.Received
+				if s.ProcessStarted.IsZero() {
+					s.ProcessStarted = c.ProcessStarted
+					s.ListenerBound = c.ListenerBound
+				}
+			})
+			w.callbackCh <- struct{}{}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// withEnv wraps the given constructor to add the environment variable to all
+// containers of the pods.
+func withEnv(podFn func(string, string) *corev1.Pod, name, value string) func(string, string) *corev1.Pod {
+	return func(ns, podName string) *corev1.Pod {
+		p := podFn(ns, podName)
+		for i := range p.Spec.Containers {
+			p.Spec.Containers[i].Env = append(p.Spec.Containers[i].Env, corev1.EnvVar{Name: name, Value: value})
+		}
+		return p
+	}
+}
@@ -0,0 +1,79 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// createService creates a Service that selects all pods of the run, targeting
+// the port of the application.
+func createService(ctx context.Context, kube kubernetes.Interface, opts Options, runLabels labels.Set) (*corev1.Service, error) {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      opts.Prefix + "-" + uuid.NewString()[:8],
+			Namespace: opts.Namespace,
+			Labels:    runLabels,
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: runLabels,
+			Ports: []corev1.ServicePort{{
+				Name:       "http",
+				Port:       80,
+				TargetPort: intstr.FromInt(appPort(opts)),
+			}},
+		},
+	}
+	created, err := kube.CoreV1().Services(opts.Namespace).Create(ctx, svc, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create service: %w", err)
+	}
+	return created, nil
+}
+
+// watchEndpoints watches the EndpointSlices of the given Service and records
+// when each pod first appears as a ready endpoint.
+func watchEndpoints(ctx context.Context, kube kubernetes.Interface, ns, service string, w *podWatcher) (watch.Interface, error) {
+	watcher, err := kube.DiscoveryV1().EndpointSlices(ns).Watch(ctx, metav1.ListOptions{
+		LabelSelector: labels.Set{discoveryv1.LabelServiceName: service}.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup watch for endpointslices: %w", err)
+	}
+
+	go func() {
+		seen := make(map[string]bool)
+		for event := range watcher.ResultChan() {
+			slice, ok := event.Object.(*discoveryv1.EndpointSlice)
+			if !ok || event.Type == watch.Deleted {
+				continue
+			}
+			now := time.Now()
+			for _, endpoint := range slice.Endpoints {
+				if endpoint.TargetRef == nil || endpoint.TargetRef.Kind != "Pod" || seen[endpoint.TargetRef.Name] {
+					continue
+				}
+				// A nil ready condition is to be interpreted as ready.
+				if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+					continue
+				}
+				seen[endpoint.TargetRef.Name] = true
+				w.tracker.Update(endpoint.TargetRef.Name, func(s *pod.Stats) {
+					s.EndpointReady = now
+				})
+				w.endpointCh <- struct{}{}
+			}
+		}
+	}()
+	return watcher, nil
+}
@@ -0,0 +1,41 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/record"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// watchEvents watches the Kubernetes Events of pods in the given namespace and
+// records them for the pods tracked by the podWatcher. The Events of tracked
+// pods are also passed to the recorder, if set.
+func watchEvents(ctx context.Context, kube kubernetes.Interface, ns string, w *podWatcher, recorder *record.Recorder) (watch.Interface, error) {
+	watcher, err := kube.CoreV1().Events(ns).Watch(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("involvedObject.kind", "Pod").String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup watch for events: %w", err)
+	}
+
+	go func() {
+		for event := range watcher.ResultChan() {
+			e, ok := event.Object.(*corev1.Event)
+			if !ok || event.Type == watch.Deleted {
+				continue
+			}
+			now := time.Now()
+			if recorder != nil && w.tracker.Has(e.InvolvedObject.Name) {
+				recorder.Event(e, now)
+			}
+			w.tracker.ObserveEvent(e, now)
+		}
+	}()
+	return watcher, nil
+}
@@ -0,0 +1,199 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ScaleOptions configures a scale benchmark against an existing workload.
+type ScaleOptions struct {
+	// Namespace is the namespace of the workload.
+	Namespace string
+	// Workload is the kind of the workload, either WorkloadDeployment or
+	// WorkloadReplicaSet.
+	Workload Workload
+	// Name is the name of the workload.
+	Name string
+	// From is the amount of replicas to start from. The current amount of
+	// replicas is used if negative.
+	From int32
+	// To is the amount of replicas to scale to.
+	To int32
+	// SkipDelete keeps the workload scaled to To if true. Otherwise it's scaled
+	// back to From after the measurement.
+	SkipDelete bool
+	// Probe probes the new pods as soon as they have an IP address if true.
+	Probe bool
+	// Tracker, if set, records the Stats of the new pods. It allows observing
+	// the progress of the run.
+	Tracker *pod.Tracker
+}
+
+// Scale scales an existing workload from one amount of replicas to another and
+// measures how long it takes for the new pods to become ready. The pods are
+// identified via the workload's selector.
+func Scale(ctx context.Context, kube kubernetes.Interface, opts ScaleOptions) (*Result, error) {
+	if opts.Workload != WorkloadDeployment && opts.Workload != WorkloadReplicaSet {
+		return nil, fmt.Errorf("unsupported workload %q, must be %q or %q", opts.Workload, WorkloadDeployment, WorkloadReplicaSet)
+	}
+
+	selector, current, err := getScale(ctx, kube, opts)
+	if err != nil {
+		return nil, err
+	}
+	from := opts.From
+	if from < 0 {
+		from = current
+	}
+	if opts.To <= from {
+		return nil, fmt.Errorf("can only scale up, but %d replicas is not more than %d", opts.To, from)
+	}
+
+	if from != current {
+		if err := setReplicas(ctx, kube, opts, from); err != nil {
+			return nil, err
+		}
+	}
+	if err := waitForAvailable(ctx, kube, opts, from); err != nil {
+		return nil, err
+	}
+
+	// Only track pods that didn't exist before scaling.
+	existing, err := kube.CoreV1().Pods(opts.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list existing pods: %w", err)
+	}
+	ignore := sets.NewString()
+	for _, p := range existing.Items {
+		ignore.Insert(p.Name)
+	}
+
+	pods := int(opts.To - from)
+	w, err := watchPods(ctx, kube, watchOptions{
+		namespace:       opts.Namespace,
+		selector:        selector,
+		resourceVersion: existing.ResourceVersion,
+		ignore:          ignore,
+		pods:            pods,
+		probe:           opts.Probe,
+		tracker:         opts.Tracker,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer w.Stop()
+
+	requested := time.Now()
+	if err := setReplicas(ctx, kube, opts, opts.To); err != nil {
+		return nil, err
+	}
+
+	// Wait for all new pods to become ready.
+	if err := waitForN(ctx, w.readyCh, pods); err != nil {
+		return nil, fmt.Errorf("failed to wait for pods becoming ready: %w", err)
+	}
+	if opts.Probe {
+		// And for all new pods to be probed, if we're doing that.
+		if err := waitForN(ctx, w.probedCh, pods); err != nil {
+			return nil, fmt.Errorf("failed to wait for pods be probed: %w", err)
+		}
+	}
+	if err := waitForAvailable(ctx, kube, opts, opts.To); err != nil {
+		return nil, err
+	}
+	result := &Result{TimeToAvailable: time.Since(requested)}
+
+	if !opts.SkipDelete {
+		if err := setReplicas(ctx, kube, opts, from); err != nil {
+			return nil, err
+		}
+	}
+
+	result.Stats = w.tracker.Stats()
+	for _, stats := range result.Stats {
+		stats.Requested = requested
+	}
+	return result, nil
+}
+
+// getScale returns the selector and the current amount of replicas of the
+// workload.
+func getScale(ctx context.Context, kube kubernetes.Interface, opts ScaleOptions) (string, int32, error) {
+	apps := kube.AppsV1()
+	var (
+		selector string
+		replicas int32
+	)
+	switch opts.Workload {
+	case WorkloadDeployment:
+		scale, err := apps.Deployments(opts.Namespace).GetScale(ctx, opts.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to fetch deployment: %w", err)
+		}
+		selector, replicas = scale.Status.Selector, scale.Spec.Replicas
+	case WorkloadReplicaSet:
+		scale, err := apps.ReplicaSets(opts.Namespace).GetScale(ctx, opts.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to fetch replicaset: %w", err)
+		}
+		selector, replicas = scale.Status.Selector, scale.Spec.Replicas
+	}
+	if selector == "" {
+		return "", 0, fmt.Errorf("%s %q has no selector", opts.Workload, opts.Name)
+	}
+	return selector, replicas, nil
+}
+
+func setReplicas(ctx context.Context, kube kubernetes.Interface, opts ScaleOptions, replicas int32) error {
+	patch := []byte(fmt.Sprintf(`{"spec":{"replicas":%d}}`, replicas))
+
+	var err error
+	switch opts.Workload {
+	case WorkloadDeployment:
+		_, err = kube.AppsV1().Deployments(opts.Namespace).Patch(ctx, opts.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case WorkloadReplicaSet:
+		_, err = kube.AppsV1().ReplicaSets(opts.Namespace).Patch(ctx, opts.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to scale %s to %d replicas: %w", opts.Workload, replicas, err)
+	}
+	return nil
+}
+
+// waitForAvailable waits until the workload has the given amount of
+// available replicas.
+func waitForAvailable(ctx context.Context, kube kubernetes.Interface, opts ScaleOptions, replicas int32) error {
+	if err := wait.PollImmediateUntil(100*time.Millisecond, func() (bool, error) {
+		switch opts.Workload {
+		case WorkloadDeployment:
+			got, err := kube.AppsV1().Deployments(opts.Namespace).Get(ctx, opts.Name, metav1.GetOptions{})
+			if err != nil {
+				return false, fmt.Errorf("failed to fetch deployment: %w", err)
+			}
+			return got.Status.ObservedGeneration >= got.Generation &&
+				got.Status.UpdatedReplicas == replicas &&
+				got.Status.AvailableReplicas == replicas, nil
+		case WorkloadReplicaSet:
+			got, err := kube.AppsV1().ReplicaSets(opts.Namespace).Get(ctx, opts.Name, metav1.GetOptions{})
+			if err != nil {
+				return false, fmt.Errorf("failed to fetch replicaset: %w", err)
+			}
+			return got.Status.ObservedGeneration >= got.Generation &&
+				got.Status.AvailableReplicas == replicas, nil
+		}
+		return false, nil
+	}, ctx.Done()); err != nil {
+		return fmt.Errorf("%s never had %d available replicas: %w", opts.Workload, replicas, err)
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestScale(t *testing.T) {
+	tests := []struct {
+		name         string
+		workload     Workload
+		current      int32
+		from, to     int32
+		skipDelete   bool
+		wantPods     int
+		wantReplicas int32
+	}{{
+		name:         "deployment from current",
+		workload:     WorkloadDeployment,
+		current:      1,
+		from:         -1,
+		to:           3,
+		wantPods:     2,
+		wantReplicas: 1,
+	}, {
+		name:         "replicaset from zero",
+		workload:     WorkloadReplicaSet,
+		current:      2,
+		from:         0,
+		to:           2,
+		wantPods:     2,
+		wantReplicas: 0,
+	}, {
+		name:         "skip delete",
+		workload:     WorkloadDeployment,
+		current:      1,
+		from:         -1,
+		to:           2,
+		skipDelete:   true,
+		wantPods:     1,
+		wantReplicas: 2,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			kube := fake.NewSimpleClientset()
+			startScaling(ctx, t, kube, test.workload, test.current)
+
+			result, err := Scale(ctx, kube, ScaleOptions{
+				Namespace:  testNamespace,
+				Workload:   test.workload,
+				Name:       "app",
+				From:       test.from,
+				To:         test.to,
+				SkipDelete: test.skipDelete,
+			})
+			if err != nil {
+				t.Fatalf("Scale() = %v", err)
+			}
+
+			// Only the new pods are measured.
+			if len(result.Stats) != test.wantPods {
+				t.Errorf("got stats of %d pods, want %d", len(result.Stats), test.wantPods)
+			}
+			for name, s := range result.Stats {
+				if s.Requested.IsZero() || s.Ready.IsZero() {
+					t.Errorf("stats of %s = %+v, want requested and ready", name, s)
+				}
+			}
+			if result.TimeToAvailable <= 0 {
+				t.Errorf("TimeToAvailable = %v, want it set", result.TimeToAvailable)
+			}
+			if got := replicasOf(ctx, kube, test.workload); got != test.wantReplicas {
+				t.Errorf("replicas = %d, want %d", got, test.wantReplicas)
+			}
+		})
+	}
+}
+
+func TestScaleErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		workload Workload
+		from, to int32
+		wantErr  string
+	}{{
+		name:     "unsupported workload",
+		workload: WorkloadStatefulSet,
+		to:       2,
+		wantErr:  `unsupported workload "statefulset"`,
+	}, {
+		name:     "scale down",
+		workload: WorkloadDeployment,
+		from:     -1,
+		to:       1,
+		wantErr:  "can only scale up",
+	}, {
+		name:     "missing workload",
+		workload: WorkloadReplicaSet,
+		from:     -1,
+		to:       3,
+		wantErr:  "failed to fetch replicaset",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			kube := fake.NewSimpleClientset()
+			// Only a Deployment with 2 replicas exists.
+			startScaling(ctx, t, kube, WorkloadDeployment, 2)
+
+			_, err := Scale(ctx, kube, ScaleOptions{
+				Namespace: testNamespace,
+				Workload:  test.workload,
+				Name:      "app",
+				From:      test.from,
+				To:        test.to,
+			})
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("Scale() = %v, want an error containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+var scaleLabels = map[string]string{"app": "scaled"}
+
+// startScaling creates the given workload with the given replicas and plays
+// its controller and the kubelet: the workload always gets as many ready pods
+// as it has replicas.
+func startScaling(ctx context.Context, t *testing.T, kube *fake.Clientset, workload Workload, replicas int32) {
+	// The fake clientset doesn't implement the scale subresource.
+	kube.PrependReactor("get", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "scale" {
+			return false, nil, nil
+		}
+		obj, err := kube.Tracker().Get(action.GetResource(), action.GetNamespace(), action.(k8stesting.GetAction).GetName())
+		if err != nil {
+			return true, nil, err
+		}
+		scale := &autoscalingv1.Scale{
+			Status: autoscalingv1.ScaleStatus{Selector: metav1.FormatLabelSelector(&metav1.LabelSelector{MatchLabels: scaleLabels})},
+		}
+		switch o := obj.(type) {
+		case *appsv1.Deployment:
+			scale.Spec.Replicas = *o.Spec.Replicas
+		case *appsv1.ReplicaSet:
+			scale.Spec.Replicas = *o.Spec.Replicas
+		}
+		return true, scale, nil
+	})
+
+	pods, err := kube.CoreV1().Pods(testNamespace).Watch(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to watch pods: %v", err)
+	}
+	go func() {
+		<-ctx.Done()
+		pods.Stop()
+	}()
+	go readyPods(ctx, t, kube, pods)
+
+	meta := metav1.ObjectMeta{Namespace: testNamespace, Name: "app"}
+	selector := &metav1.LabelSelector{MatchLabels: scaleLabels}
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: scaleLabels},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "example.com/app"}}},
+	}
+	switch workload {
+	case WorkloadDeployment:
+		_, err = kube.AppsV1().Deployments(testNamespace).Create(ctx, &appsv1.Deployment{
+			ObjectMeta: meta,
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas, Selector: selector, Template: template},
+		}, metav1.CreateOptions{})
+	case WorkloadReplicaSet:
+		_, err = kube.AppsV1().ReplicaSets(testNamespace).Create(ctx, &appsv1.ReplicaSet{
+			ObjectMeta: meta,
+			Spec:       appsv1.ReplicaSetSpec{Replicas: &replicas, Selector: selector, Template: template},
+		}, metav1.CreateOptions{})
+	}
+	if err != nil {
+		t.Fatalf("failed to create %s: %v", workload, err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			reconcile(ctx, t, kube, workload, replicasOf(ctx, kube, workload))
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// reconcile creates or deletes pods until there are as many as the given
+// replicas and reports them as available.
+func reconcile(ctx context.Context, t *testing.T, kube *fake.Clientset, workload Workload, replicas int32) {
+	list, err := kube.CoreV1().Pods(testNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return
+	}
+	names := make(map[string]bool, len(list.Items))
+	for _, p := range list.Items {
+		names[p.Name] = true
+	}
+	for i := int32(0); i < replicas; i++ {
+		name := fmt.Sprintf("app-%d", i)
+		if names[name] {
+			continue
+		}
+		p := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: name, Labels: scaleLabels},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "example.com/app"}}},
+		}
+		if _, err := kube.CoreV1().Pods(testNamespace).Create(ctx, p, metav1.CreateOptions{}); err != nil {
+			t.Errorf("failed to create pod: %v", err)
+		}
+	}
+	for i := replicas; i < int32(len(list.Items)); i++ {
+		kube.CoreV1().Pods(testNamespace).Delete(ctx, fmt.Sprintf("app-%d", i), metav1.DeleteOptions{})
+	}
+
+	// Patch the status only, to not undo concurrent scaling.
+	patch := []byte(fmt.Sprintf(`{"status":{"availableReplicas":%d,"updatedReplicas":%d}}`, replicas, replicas))
+	switch workload {
+	case WorkloadDeployment:
+		kube.AppsV1().Deployments(testNamespace).Patch(ctx, "app", types.MergePatchType, patch, metav1.PatchOptions{}, "status")
+	case WorkloadReplicaSet:
+		kube.AppsV1().ReplicaSets(testNamespace).Patch(ctx, "app", types.MergePatchType, patch, metav1.PatchOptions{}, "status")
+	}
+}
+
+// replicasOf returns the desired replicas of the workload named "app".
+func replicasOf(ctx context.Context, kube *fake.Clientset, workload Workload) int32 {
+	switch workload {
+	case WorkloadDeployment:
+		if d, err := kube.AppsV1().Deployments(testNamespace).Get(ctx, "app", metav1.GetOptions{}); err == nil {
+			return *d.Spec.Replicas
+		}
+	case WorkloadReplicaSet:
+		if rs, err := kube.AppsV1().ReplicaSets(testNamespace).Get(ctx, "app", metav1.GetOptions{}); err == nil {
+			return *rs.Spec.Replicas
+		}
+	}
+	return 0
+}
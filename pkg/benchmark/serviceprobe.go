@@ -0,0 +1,81 @@
+package benchmark
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+	"github.com/markusthoemmes/podspeed/pkg/startup"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// ServiceProbe defines how to address the Service when probing through it.
+type ServiceProbe string
+
+const (
+	// ServiceProbeIP probes the Service via its ClusterIP.
+	ServiceProbeIP ServiceProbe = "ip"
+	// ServiceProbeDNS probes the Service via its cluster-local DNS name.
+	ServiceProbeDNS ServiceProbe = "dns"
+)
+
+// probeService continuously sends requests to the given Service and records
+// when a request first landed on each pod. It stops once the context is done.
+func probeService(ctx context.Context, svc *corev1.Service, mode ServiceProbe, w *podWatcher) {
+	host := svc.Spec.ClusterIP
+	if mode == ServiceProbeDNS {
+		host = fmt.Sprintf("%s.%s.svc", svc.Name, svc.Namespace)
+	}
+	url := fmt.Sprintf("http://%s:%d", host, svc.Spec.Ports[0].Port)
+
+	client := &http.Client{
+		Timeout: time.Second,
+		Transport: &http.Transport{
+			// Use a new connection for each request to have them balanced
+			// across all pods.
+			DisableKeepAlives: true,
+		},
+	}
+
+	seen := make(map[string]bool)
+	wait.Until(func() {
+		name := servingPod(ctx, client, url)
+		if name == "" || seen[name] || !w.tracker.Has(name) {
+			return
+		}
+		seen[name] = true
+		w.tracker.Update(name, func(s *pod.Stats) {
+			s.ServiceProbed = time.Now()
+		})
+		w.serviceProbedCh <- struct{}{}
+	}, 10*time.Millisecond, ctx.Done())
+}
+
+// servingPod sends a request to the given URL and returns the name of the pod
+// that served it, either from the startup.PodNameHeader or the first line of the body.
+// It returns an empty string if the request failed.
+func servingPod(ctx context.Context, client *http.Client, url string) string {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return ""
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ""
+	}
+
+	if name := resp.Header.Get(startup.PodNameHeader); name != "" {
+		return name
+	}
+	line, _ := bufio.NewReader(resp.Body).ReadString('\n')
+	return strings.TrimSpace(line)
+}
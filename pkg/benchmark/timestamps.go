@@ -0,0 +1,59 @@
+package benchmark
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+	"github.com/markusthoemmes/podspeed/pkg/startup"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// collectTimestamps fetches the startup timestamps the application in the
+// given pod reports about itself and records them.
+func collectTimestamps(ctx context.Context, opts Options, w *podWatcher, name string) error {
+	var ip string
+	w.tracker.Update(name, func(s *pod.Stats) {
+		ip = s.IP
+	})
+	url := "http://" + ip + ":" + strconv.Itoa(appPort(opts)) + startup.Path
+
+	var timestamps startup.Timestamps
+	if err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return false, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return false, nil
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return false, nil
+		}
+		return true, json.NewDecoder(resp.Body).Decode(&timestamps)
+	}); err != nil {
+		return fmt.Errorf("failed to fetch startup timestamps of pod %s: %w", name, err)
+	}
+
+	w.tracker.Update(name, func(s *pod.Stats) {
+		s.ProcessStarted = timestamps.ProcessStarted
+		s.ListenerBound = timestamps.ListenerBound
+		s.FirstRequest = timestamps.FirstRequest
+	})
+	return nil
+}
+
+// appPort returns the first port of the first container of the pods, or 8080
+// if there is none.
+func appPort(opts Options) int {
+	if p := opts.PodFn(opts.Namespace, ""); len(p.Spec.Containers) > 0 && len(p.Spec.Containers[0].Ports) > 0 {
+		return int(p.Spec.Containers[0].Ports[0].ContainerPort)
+	}
+	return 8080
+}
@@ -0,0 +1,264 @@
+package benchmark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	podtemplate "github.com/markusthoemmes/podspeed/pkg/pod/template"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Workload is the kind of object created to get pods.
+type Workload string
+
+const (
+	WorkloadPod         Workload = "pod"
+	WorkloadDeployment  Workload = "deployment"
+	WorkloadReplicaSet  Workload = "replicaset"
+	WorkloadStatefulSet Workload = "statefulset"
+	WorkloadJob         Workload = "job"
+)
+
+// Workloads are all supported workloads.
+var Workloads = []Workload{WorkloadPod, WorkloadDeployment, WorkloadReplicaSet, WorkloadStatefulSet, WorkloadJob}
+
+// Supported returns whether the workload is one of Workloads.
+func (w Workload) Supported() bool {
+	for _, supported := range Workloads {
+		if w == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// WorkloadNames returns the names of all supported workloads.
+func WorkloadNames() []string {
+	names := make([]string, 0, len(Workloads))
+	for _, w := range Workloads {
+		names = append(names, string(w))
+	}
+	return names
+}
+
+// runWorkload creates a controller for all pods at once and waits for all of
+// its pods to become ready, or for the Job to complete.
+func runWorkload(ctx context.Context, kube kubernetes.Interface, opts Options, runLabels labels.Set, w *podWatcher) (*Result, error) {
+	if opts.Companions != nil {
+		objs, err := opts.Companions(podtemplate.ScopePod, opts.Namespace, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate companion objects: %w", err)
+		}
+		if len(objs) > 0 {
+			return nil, fmt.Errorf("companion objects with scope %q are not supported for workload %q", podtemplate.ScopePod, opts.Workload)
+		}
+	}
+
+	name := opts.Prefix + "-" + uuid.NewString()[:8]
+	p := opts.PodFn(opts.Namespace, "")
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: runLabels,
+		},
+		Spec: p.Spec,
+	}
+	selector := &metav1.LabelSelector{
+		MatchLabels: runLabels,
+	}
+	meta := metav1.ObjectMeta{
+		Name:      name,
+		Namespace: opts.Namespace,
+		Labels:    runLabels,
+	}
+	replicas := int32(opts.Pods)
+
+	requested := time.Now()
+	var err error
+	switch opts.Workload {
+	case WorkloadDeployment:
+		_, err = kube.AppsV1().Deployments(opts.Namespace).Create(ctx, &appsv1.Deployment{
+			ObjectMeta: meta,
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: selector,
+				Template: template,
+			},
+		}, metav1.CreateOptions{})
+	case WorkloadReplicaSet:
+		_, err = kube.AppsV1().ReplicaSets(opts.Namespace).Create(ctx, &appsv1.ReplicaSet{
+			ObjectMeta: meta,
+			Spec: appsv1.ReplicaSetSpec{
+				Replicas: &replicas,
+				Selector: selector,
+				Template: template,
+			},
+		}, metav1.CreateOptions{})
+	case WorkloadStatefulSet:
+		_, err = kube.AppsV1().StatefulSets(opts.Namespace).Create(ctx, &appsv1.StatefulSet{
+			ObjectMeta: meta,
+			Spec: appsv1.StatefulSetSpec{
+				Replicas: &replicas,
+				Selector: selector,
+				Template: template,
+				// Create all pods at once rather than one by one.
+				PodManagementPolicy: appsv1.ParallelPodManagement,
+			},
+		}, metav1.CreateOptions{})
+	case WorkloadJob:
+		// Jobs don't support restarting their pods in place.
+		template.Spec.RestartPolicy = corev1.RestartPolicyNever
+		_, err = kube.BatchV1().Jobs(opts.Namespace).Create(ctx, &batchv1.Job{
+			ObjectMeta: meta,
+			Spec: batchv1.JobSpec{
+				Completions: &replicas,
+				Parallelism: &replicas,
+				Template:    template,
+			},
+		}, metav1.CreateOptions{})
+	default:
+		return nil, fmt.Errorf("unsupported workload %q", opts.Workload)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", opts.Workload, err)
+	}
+
+	result := &Result{}
+	if opts.Workload == WorkloadJob {
+		completed, err := waitForJob(ctx, kube, opts.Namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		result.TimeToCompleted = completed.Sub(requested)
+	} else {
+		// Wait for all pods to become ready.
+		if err := waitForN(ctx, w.readyCh, opts.Pods); err != nil {
+			return nil, fmt.Errorf("failed to wait for pods becoming ready: %w", err)
+		}
+		result.TimeToAvailable = time.Since(requested)
+		if opts.Probe {
+			// And for all pods to be probed, if we're doing that.
+			if err := waitForN(ctx, w.probedCh, opts.Pods); err != nil {
+				return nil, fmt.Errorf("failed to wait for pods be probed: %w", err)
+			}
+		}
+		if opts.Endpoints {
+			// And for all pods to become endpoints, if we're doing that.
+			if err := waitForN(ctx, w.endpointCh, opts.Pods); err != nil {
+				return nil, fmt.Errorf("failed to wait for pods becoming endpoints: %w", err)
+			}
+		}
+		if opts.ServiceProbe != "" {
+			// And for all pods to serve a request through the Service, if
+			// we're doing that.
+			if err := waitForN(ctx, w.serviceProbedCh, opts.Pods); err != nil {
+				return nil, fmt.Errorf("failed to wait for pods be probed through the service: %w", err)
+			}
+		}
+		if opts.Callbacks != nil {
+			// And for all applications to report back, if we're doing that.
+			if err := waitForN(ctx, w.callbackCh, opts.Pods); err != nil {
+				return nil, fmt.Errorf("failed to wait for callbacks of pods: %w", err)
+			}
+		}
+		if opts.Timestamps {
+			for name := range w.tracker.Stats() {
+				if err := collectTimestamps(ctx, opts, w, name); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if !opts.SkipDelete {
+		if err := deleteWorkload(ctx, kube, opts.Namespace, opts.Workload, name); err != nil {
+			return nil, err
+		}
+		// Don't wait for the graceful termination of the pods.
+		var zero int64
+		if err := kube.CoreV1().Pods(opts.Namespace).DeleteCollection(ctx, metav1.DeleteOptions{
+			GracePeriodSeconds: &zero,
+		}, metav1.ListOptions{
+			LabelSelector: runLabels.String(),
+		}); err != nil {
+			return nil, fmt.Errorf("failed to delete pods: %w", err)
+		}
+		if err := waitForN(ctx, w.deletedCh, opts.Pods); err != nil {
+			return nil, fmt.Errorf("failed to wait for pods being deleted: %w", err)
+		}
+	}
+
+	result.Stats = w.tracker.Stats()
+	for _, stats := range result.Stats {
+		stats.Requested = requested
+	}
+	return result, nil
+}
+
+// waitForJob waits for the given Job to complete and returns the time it was
+// observed to be complete at.
+func waitForJob(ctx context.Context, kube kubernetes.Interface, ns, name string) (time.Time, error) {
+	watcher, err := kube.BatchV1().Jobs(ns).Watch(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", name).String(),
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to setup watch for job: %w", err)
+	}
+	defer watcher.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return time.Time{}, errors.New("watch for job closed unexpectedly")
+			}
+			job, ok := event.Object.(*batchv1.Job)
+			if !ok {
+				continue
+			}
+			for _, cond := range job.Status.Conditions {
+				if cond.Status != corev1.ConditionTrue {
+					continue
+				}
+				switch cond.Type {
+				case batchv1.JobComplete:
+					return time.Now(), nil
+				case batchv1.JobFailed:
+					return time.Time{}, fmt.Errorf("job failed: %s", cond.Message)
+				}
+			}
+		case <-ctx.Done():
+			return time.Time{}, fmt.Errorf("failed to wait for job to complete: %w", ctx.Err())
+		}
+	}
+}
+
+func deleteWorkload(ctx context.Context, kube kubernetes.Interface, ns string, workload Workload, name string) error {
+	// Stop the controller from managing its pods before they're deleted.
+	propagation := metav1.DeletePropagationForeground
+	opts := metav1.DeleteOptions{PropagationPolicy: &propagation}
+
+	var err error
+	switch workload {
+	case WorkloadDeployment:
+		err = kube.AppsV1().Deployments(ns).Delete(ctx, name, opts)
+	case WorkloadReplicaSet:
+		err = kube.AppsV1().ReplicaSets(ns).Delete(ctx, name, opts)
+	case WorkloadStatefulSet:
+		err = kube.AppsV1().StatefulSets(ns).Delete(ctx, name, opts)
+	case WorkloadJob:
+		err = kube.BatchV1().Jobs(ns).Delete(ctx, name, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", workload, err)
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	podtemplate "github.com/markusthoemmes/podspeed/pkg/pod/template"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testNamespace = "test"
+
+func TestRunWorkload(t *testing.T) {
+	tests := []struct {
+		workload Workload
+		job      bool
+	}{
+		{workload: WorkloadDeployment},
+		{workload: WorkloadReplicaSet},
+		{workload: WorkloadStatefulSet},
+		{workload: WorkloadJob, job: true},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.workload), func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			kube := fake.NewSimpleClientset()
+			startCluster(ctx, t, kube)
+
+			opts := testOptions()
+			opts.Workload = test.workload
+			opts.Pods = 3
+			result, err := Run(ctx, kube, opts)
+			if err != nil {
+				t.Fatalf("Run() = %v", err)
+			}
+
+			if len(result.Stats) != opts.Pods {
+				t.Errorf("got stats of %d pods, want %d", len(result.Stats), opts.Pods)
+			}
+			for name, s := range result.Stats {
+				if s.Requested.IsZero() || s.Ready.IsZero() {
+					t.Errorf("stats of %s = %+v, want requested and ready", name, s)
+				}
+			}
+			if test.job && (result.TimeToCompleted <= 0 || result.TimeToAvailable != 0) {
+				t.Errorf("TimeToCompleted, TimeToAvailable = %v, %v, want only the former", result.TimeToCompleted, result.TimeToAvailable)
+			}
+			if !test.job && (result.TimeToAvailable <= 0 || result.TimeToCompleted != 0) {
+				t.Errorf("TimeToAvailable, TimeToCompleted = %v, %v, want only the former", result.TimeToAvailable, result.TimeToCompleted)
+			}
+
+			pods, err := kube.CoreV1().Pods(testNamespace).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("failed to list pods: %v", err)
+			}
+			if len(pods.Items) != 0 {
+				t.Errorf("%d pods were left behind", len(pods.Items))
+			}
+		})
+	}
+}
+
+func TestRunWorkloadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    func(*Options)
+		wantErr string
+	}{{
+		name:    "unsupported workload",
+		opts:    func(o *Options) { o.Workload = "daemonset" },
+		wantErr: `unsupported workload "daemonset"`,
+	}, {
+		name: "pod scoped companions",
+		opts: func(o *Options) {
+			o.Workload = WorkloadDeployment
+			o.Companions = func(scope podtemplate.Scope, ns, pod string) ([]runtime.Object, error) {
+				if scope == podtemplate.ScopePod {
+					return []runtime.Object{&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "config"}}}, nil
+				}
+				return nil, nil
+			}
+		},
+		wantErr: `scope "pod" are not supported for workload "deployment"`,
+	}, {
+		name: "spread across namespaces",
+		opts: func(o *Options) {
+			o.Workload = WorkloadDeployment
+			o.Namespaces = []string{"a", "b"}
+		},
+		wantErr: "only supported for bare pods",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := testOptions()
+			test.opts(&opts)
+			_, err := Run(context.Background(), fake.NewSimpleClientset(), opts)
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("Run() = %v, want an error containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func testOptions() Options {
+	return Options{
+		Namespace: testNamespace,
+		Prefix:    "test",
+		Pods:      1,
+		PodFn: func(ns, name string) *corev1.Pod {
+			return &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "test", Image: "example.com/app"}}},
+			}
+		},
+	}
+}
+
+// startCluster plays the controllers and the kubelet on the fake clientset:
+// workloads get their pods, all pods become ready and Jobs complete right away.
+// Deleting a collection of pods deletes all of them.
+func startCluster(ctx context.Context, t *testing.T, kube *fake.Clientset) {
+	kube.PrependReactor("delete-collection", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		gvr := corev1.SchemeGroupVersion.WithResource("pods")
+		list, err := kube.Tracker().List(gvr, corev1.SchemeGroupVersion.WithKind("Pod"), action.GetNamespace())
+		if err != nil {
+			return true, nil, err
+		}
+		for _, p := range list.(*corev1.PodList).Items {
+			if err := kube.Tracker().Delete(gvr, p.Namespace, p.Name); err != nil {
+				return true, nil, err
+			}
+		}
+		return true, nil, nil
+	})
+
+	untilDone := func(w watch.Interface, err error) watch.Interface {
+		if err != nil {
+			t.Fatalf("failed to watch: %v", err)
+		}
+		go func() {
+			<-ctx.Done()
+			w.Stop()
+		}()
+		return w
+	}
+	pods := untilDone(kube.CoreV1().Pods(testNamespace).Watch(ctx, metav1.ListOptions{}))
+	deployments := untilDone(kube.AppsV1().Deployments(testNamespace).Watch(ctx, metav1.ListOptions{}))
+	replicaSets := untilDone(kube.AppsV1().ReplicaSets(testNamespace).Watch(ctx, metav1.ListOptions{}))
+	statefulSets := untilDone(kube.AppsV1().StatefulSets(testNamespace).Watch(ctx, metav1.ListOptions{}))
+	jobs := untilDone(kube.BatchV1().Jobs(testNamespace).Watch(ctx, metav1.ListOptions{}))
+
+	go readyPods(ctx, t, kube, pods)
+	go func() {
+		for {
+			var (
+				event    watch.Event
+				ok       bool
+				name     string
+				replicas int32
+				template corev1.PodTemplateSpec
+			)
+			select {
+			case event, ok = <-deployments.ResultChan():
+				if d, isType := event.Object.(*appsv1.Deployment); isType {
+					name, replicas, template = d.Name, *d.Spec.Replicas, d.Spec.Template
+				}
+			case event, ok = <-replicaSets.ResultChan():
+				if rs, isType := event.Object.(*appsv1.ReplicaSet); isType {
+					name, replicas, template = rs.Name, *rs.Spec.Replicas, rs.Spec.Template
+				}
+			case event, ok = <-statefulSets.ResultChan():
+				if ss, isType := event.Object.(*appsv1.StatefulSet); isType {
+					name, replicas, template = ss.Name, *ss.Spec.Replicas, ss.Spec.Template
+				}
+			case event, ok = <-jobs.ResultChan():
+				if job, isType := event.Object.(*batchv1.Job); isType {
+					name, replicas, template = job.Name, *job.Spec.Completions, job.Spec.Template
+					if event.Type == watch.Added {
+						go completeJob(ctx, kube, job)
+					}
+				}
+			}
+			if !ok {
+				return
+			}
+			if event.Type != watch.Added {
+				continue
+			}
+			for i := int32(0); i < replicas; i++ {
+				p := &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: fmt.Sprintf("%s-%d", name, i), Labels: template.Labels},
+					Spec:       template.Spec,
+				}
+				if _, err := kube.CoreV1().Pods(testNamespace).Create(ctx, p, metav1.CreateOptions{}); err != nil {
+					t.Errorf("failed to create pod: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// readyPods makes every pod seen by the watch ready right away.
+func readyPods(ctx context.Context, t *testing.T, kube *fake.Clientset, pods watch.Interface) {
+	for event := range pods.ResultChan() {
+		p, ok := event.Object.(*corev1.Pod)
+		if !ok || event.Type != watch.Added {
+			continue
+		}
+		p = p.DeepCopy()
+		p.Spec.NodeName = "node-1"
+		p.Status.Phase = corev1.PodRunning
+		for _, typ := range []corev1.PodConditionType{corev1.PodScheduled, corev1.PodInitialized, corev1.ContainersReady, corev1.PodReady} {
+			p.Status.Conditions = append(p.Status.Conditions, corev1.PodCondition{Type: typ, Status: corev1.ConditionTrue})
+		}
+		if _, err := kube.CoreV1().Pods(p.Namespace).UpdateStatus(ctx, p, metav1.UpdateOptions{}); err != nil {
+			t.Errorf("failed to start pod: %v", err)
+		}
+	}
+}
+
+// completeJob marks the Job complete until it's deleted, as the watch for its
+// completion might only be established after the first update.
+func completeJob(ctx context.Context, kube *fake.Clientset, job *batchv1.Job) {
+	job = job.DeepCopy()
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := kube.BatchV1().Jobs(job.Namespace).UpdateStatus(ctx, job, metav1.UpdateOptions{}); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package callback
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/startup"
+)
+
+// Callback is a Report received from an application.
+type Callback struct {
+	startup.Report
+
+	// Received is when the Report was received.
+	Received time.Time
+}
+
+// Handler returns a handler that accepts Reports via POST and sends them to
+// the given channel.
+func Handler(callbacks chan<- Callback) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received := time.Now()
+		if r.Method != http.MethodPost {
+			http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var report startup.Report
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			http.Error(w, "failed to decode report: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if report.Pod == "" {
+			http.Error(w, "report has no pod", http.StatusBadRequest)
+			return
+		}
+
+		select {
+		case callbacks <- Callback{Report: report, Received: received}:
+			w.WriteHeader(http.StatusNoContent)
+		case <-r.Context().Done():
+		}
+	})
+}
+
+// Serve serves the Handler on the given address until the context is done.
+func Serve(ctx context.Context, addr string, callbacks chan<- Callback) error {
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: Handler(callbacks),
+	}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve callbacks: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package callback
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/startup"
+)
+
+func TestHandler(t *testing.T) {
+	callbacks := make(chan Callback, 1)
+	server := httptest.NewServer(Handler(callbacks))
+	defer server.Close()
+
+	want := startup.Report{
+		Pod: "test-pod",
+		Timestamps: startup.Timestamps{
+			ProcessStarted: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+			ListenerBound:  time.Date(2021, 1, 1, 0, 0, 1, 0, time.UTC),
+		},
+	}
+	body, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("failed to encode report: %v", err)
+	}
+	before := time.Now()
+	resp, err := http.Post(server.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to post report: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+
+	select {
+	case got := <-callbacks:
+		if got.Pod != want.Pod || !got.ProcessStarted.Equal(want.ProcessStarted) || !got.ListenerBound.Equal(want.ListenerBound) {
+			t.Errorf("report = %+v, want %+v", got.Report, want)
+		}
+		if got.Received.Before(before) {
+			t.Errorf("received = %v, want it after %v", got.Received, before)
+		}
+	default:
+		t.Fatal("no callback was received")
+	}
+}
+
+func TestHandlerRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		want   int
+	}{{
+		name:   "not a POST",
+		method: http.MethodGet,
+		want:   http.StatusMethodNotAllowed,
+	}, {
+		name:   "malformed",
+		method: http.MethodPost,
+		body:   "{",
+		want:   http.StatusBadRequest,
+	}, {
+		name:   "no pod",
+		method: http.MethodPost,
+		body:   `{"processStarted": "2021-01-01T00:00:00Z"}`,
+		want:   http.StatusBadRequest,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			callbacks := make(chan Callback, 1)
+			rec := httptest.NewRecorder()
+			Handler(callbacks).ServeHTTP(rec, httptest.NewRequest(test.method, "/", bytes.NewBufferString(test.body)))
+
+			if rec.Code != test.want {
+				t.Errorf("status = %d, want %d", rec.Code, test.want)
+			}
+			if len(callbacks) != 0 {
+				t.Errorf("got %d callbacks, want none", len(callbacks))
+			}
+		})
+	}
+}
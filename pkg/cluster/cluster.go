@@ -0,0 +1,71 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Info describes a cluster and its nodes.
+type Info struct {
+	ServerVersion string `json:"serverVersion"`
+	NodeCount     int    `json:"nodeCount"`
+	Nodes         []Node `json:"nodes"`
+}
+
+// Node describes the software and placement of a single node.
+type Node struct {
+	Name                    string `json:"name"`
+	KubeletVersion          string `json:"kubeletVersion"`
+	ContainerRuntimeVersion string `json:"containerRuntimeVersion"`
+	KernelVersion           string `json:"kernelVersion"`
+	OSImage                 string `json:"osImage"`
+	Architecture            string `json:"architecture"`
+	InstanceType            string `json:"instanceType,omitempty"`
+	Zone                    string `json:"zone,omitempty"`
+}
+
+// Collect gathers the Info of the cluster the client talks to.
+func Collect(ctx context.Context, kube kubernetes.Interface) (*Info, error) {
+	version, err := kube.Discovery().ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server version: %w", err)
+	}
+	nodes, err := kube.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	info := &Info{
+		ServerVersion: version.GitVersion,
+		NodeCount:     len(nodes.Items),
+		Nodes:         make([]Node, 0, len(nodes.Items)),
+	}
+	for _, n := range nodes.Items {
+		info.Nodes = append(info.Nodes, Node{
+			Name:                    n.Name,
+			KubeletVersion:          n.Status.NodeInfo.KubeletVersion,
+			ContainerRuntimeVersion: n.Status.NodeInfo.ContainerRuntimeVersion,
+			KernelVersion:           n.Status.NodeInfo.KernelVersion,
+			OSImage:                 n.Status.NodeInfo.OSImage,
+			Architecture:            n.Status.NodeInfo.Architecture,
+			InstanceType:            label(n, corev1.LabelInstanceTypeStable, corev1.LabelInstanceType),
+			Zone:                    label(n, corev1.LabelTopologyZone, corev1.LabelFailureDomainBetaZone),
+		})
+	}
+	return info, nil
+}
+
+// label returns the value of the first of the given labels the node has.
+// Older clusters only set the deprecated variants.
+func label(n corev1.Node, keys ...string) string {
+	for _, key := range keys {
+		if v := n.Labels[key]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
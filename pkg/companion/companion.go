@@ -0,0 +1,113 @@
+package companion
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Create creates the given companion object in the given namespace. Server
+// populated fields, as present in objects exported from a cluster, are reset.
+func Create(ctx context.Context, kube kubernetes.Interface, ns string, labels map[string]string, obj runtime.Object) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return fmt.Errorf("failed to access object metadata: %w", err)
+	}
+	accessor.SetNamespace(ns)
+	accessor.SetResourceVersion("")
+	accessor.SetUID("")
+	accessor.SetCreationTimestamp(metav1.Time{})
+	accessor.SetManagedFields(nil)
+	objLabels := accessor.GetLabels()
+	if objLabels == nil {
+		objLabels = make(map[string]string, len(labels))
+	}
+	for k, v := range labels {
+		objLabels[k] = v
+	}
+	accessor.SetLabels(objLabels)
+
+	switch o := obj.(type) {
+	case *corev1.ConfigMap:
+		_, err = kube.CoreV1().ConfigMaps(ns).Create(ctx, o, metav1.CreateOptions{})
+	case *corev1.Secret:
+		_, err = kube.CoreV1().Secrets(ns).Create(ctx, o, metav1.CreateOptions{})
+	case *corev1.Service:
+		if o.Spec.ClusterIP != corev1.ClusterIPNone {
+			o.Spec.ClusterIP = ""
+			o.Spec.ClusterIPs = nil
+		}
+		o.Status = corev1.ServiceStatus{}
+		_, err = kube.CoreV1().Services(ns).Create(ctx, o, metav1.CreateOptions{})
+	case *corev1.PersistentVolumeClaim:
+		o.Status = corev1.PersistentVolumeClaimStatus{}
+		_, err = kube.CoreV1().PersistentVolumeClaims(ns).Create(ctx, o, metav1.CreateOptions{})
+	default:
+		return fmt.Errorf("unsupported companion object %T", obj)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create %s %q: %w", kind(obj), accessor.GetName(), err)
+	}
+	return nil
+}
+
+// Delete deletes the given companion object from the given namespace.
+func Delete(ctx context.Context, kube kubernetes.Interface, ns string, obj runtime.Object) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return fmt.Errorf("failed to access object metadata: %w", err)
+	}
+	name := accessor.GetName()
+
+	switch obj.(type) {
+	case *corev1.ConfigMap:
+		err = kube.CoreV1().ConfigMaps(ns).Delete(ctx, name, metav1.DeleteOptions{})
+	case *corev1.Secret:
+		err = kube.CoreV1().Secrets(ns).Delete(ctx, name, metav1.DeleteOptions{})
+	case *corev1.Service:
+		err = kube.CoreV1().Services(ns).Delete(ctx, name, metav1.DeleteOptions{})
+	case *corev1.PersistentVolumeClaim:
+		err = kube.CoreV1().PersistentVolumeClaims(ns).Delete(ctx, name, metav1.DeleteOptions{})
+	default:
+		return fmt.Errorf("unsupported companion object %T", obj)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete %s %q: %w", kind(obj), name, err)
+	}
+	return nil
+}
+
+// Resource returns the API resource of the given companion object, i.e.
+// "configmaps".
+func Resource(obj runtime.Object) (string, error) {
+	switch obj.(type) {
+	case *corev1.ConfigMap:
+		return "configmaps", nil
+	case *corev1.Secret:
+		return "secrets", nil
+	case *corev1.Service:
+		return "services", nil
+	case *corev1.PersistentVolumeClaim:
+		return "persistentvolumeclaims", nil
+	}
+	return "", fmt.Errorf("unsupported companion object %T", obj)
+}
+
+func kind(obj runtime.Object) string {
+	switch obj.(type) {
+	case *corev1.ConfigMap:
+		return "ConfigMap"
+	case *corev1.Secret:
+		return "Secret"
+	case *corev1.Service:
+		return "Service"
+	case *corev1.PersistentVolumeClaim:
+		return "PersistentVolumeClaim"
+	}
+	return fmt.Sprintf("%T", obj)
+}
@@ -0,0 +1,104 @@
+package companion
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCreateAndDelete(t *testing.T) {
+	exported := metav1.ObjectMeta{
+		Name:            "obj",
+		Namespace:       "elsewhere",
+		ResourceVersion: "42",
+		UID:             types.UID("uid"),
+		Labels:          map[string]string{"app": "test"},
+	}
+	tests := []struct {
+		name string
+		obj  runtime.Object
+		get  func(context.Context, *fake.Clientset) (metav1.Object, error)
+	}{{
+		name: "configmap",
+		obj:  &corev1.ConfigMap{ObjectMeta: *exported.DeepCopy()},
+		get: func(ctx context.Context, kube *fake.Clientset) (metav1.Object, error) {
+			return kube.CoreV1().ConfigMaps("ns").Get(ctx, "obj", metav1.GetOptions{})
+		},
+	}, {
+		name: "secret",
+		obj:  &corev1.Secret{ObjectMeta: *exported.DeepCopy()},
+		get: func(ctx context.Context, kube *fake.Clientset) (metav1.Object, error) {
+			return kube.CoreV1().Secrets("ns").Get(ctx, "obj", metav1.GetOptions{})
+		},
+	}, {
+		name: "service",
+		obj: &corev1.Service{
+			ObjectMeta: *exported.DeepCopy(),
+			Spec:       corev1.ServiceSpec{ClusterIP: "10.0.0.1", ClusterIPs: []string{"10.0.0.1"}},
+		},
+		get: func(ctx context.Context, kube *fake.Clientset) (metav1.Object, error) {
+			svc, err := kube.CoreV1().Services("ns").Get(ctx, "obj", metav1.GetOptions{})
+			if err == nil && svc.Spec.ClusterIP != "" {
+				t.Errorf("cluster IP = %s, want it reset", svc.Spec.ClusterIP)
+			}
+			return svc, err
+		},
+	}, {
+		name: "pvc",
+		obj:  &corev1.PersistentVolumeClaim{ObjectMeta: *exported.DeepCopy()},
+		get: func(ctx context.Context, kube *fake.Clientset) (metav1.Object, error) {
+			return kube.CoreV1().PersistentVolumeClaims("ns").Get(ctx, "obj", metav1.GetOptions{})
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			kube := fake.NewSimpleClientset()
+			if err := Create(ctx, kube, "ns", map[string]string{"run": "a"}, test.obj); err != nil {
+				t.Fatalf("Create() = %v", err)
+			}
+
+			got, err := test.get(ctx, kube)
+			if err != nil {
+				t.Fatalf("failed to get object: %v", err)
+			}
+			if got.GetResourceVersion() == "42" || got.GetUID() != "" {
+				t.Errorf("server populated fields = %q, %q, want them reset", got.GetResourceVersion(), got.GetUID())
+			}
+			if want := map[string]string{"app": "test", "run": "a"}; !reflect.DeepEqual(got.GetLabels(), want) {
+				t.Errorf("labels = %v, want %v", got.GetLabels(), want)
+			}
+
+			if err := Delete(ctx, kube, "ns", test.obj); err != nil {
+				t.Fatalf("Delete() = %v", err)
+			}
+			if _, err := test.get(ctx, kube); err == nil {
+				t.Error("object still exists after Delete()")
+			}
+		})
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	obj := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "obj"}}
+	kube := fake.NewSimpleClientset()
+
+	if err := Create(context.Background(), kube, "ns", nil, obj); err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Errorf("Create() = %v, want an unsupported error", err)
+	}
+	if err := Delete(context.Background(), kube, "ns", obj); err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Errorf("Delete() = %v, want an unsupported error", err)
+	}
+	if _, err := Resource(obj); err == nil {
+		t.Error("Resource() = nil, want an error")
+	}
+}
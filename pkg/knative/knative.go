@@ -0,0 +1,361 @@
+package knative
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	// ServiceResource is the resource of Knative Services.
+	ServiceResource = schema.GroupVersionResource{Group: "serving.knative.dev", Version: "v1", Resource: "services"}
+	// RevisionResource is the resource of Knative Revisions.
+	RevisionResource = schema.GroupVersionResource{Group: "serving.knative.dev", Version: "v1", Resource: "revisions"}
+)
+
+// serviceLabel is the label Knative puts on Revisions and pods of a Service.
+const serviceLabel = "serving.knative.dev/service"
+
+// Options configures a Knative Service benchmark.
+type Options struct {
+	// Namespace is the namespace to create the Services in.
+	Namespace string
+	// Prefix is prepended to the generated names of the Services.
+	Prefix string
+	// PodFn constructs a pod whose first container is used as the container of
+	// the Services.
+	PodFn func(string, string) *corev1.Pod
+	// Services is the amount of Services to create.
+	Services int
+	// Ingress, if set, is the address requests are sent to, with the host of
+	// the Service's URL as Host header. Otherwise the Service's URL is used
+	// directly.
+	Ingress string
+	// ScaleFromZero additionally waits for each Service to scale to zero and
+	// measures the cold start of a request afterwards if true.
+	ScaleFromZero bool
+	// SkipDelete keeps the Services around after they've been measured if true.
+	SkipDelete bool
+}
+
+// Stats are the timestamps gathered for a single Knative Service.
+type Stats struct {
+	Created       time.Time
+	RevisionReady time.Time
+	PodReady      time.Time
+	FirstRequest  time.Time
+
+	ScaledToZero       time.Time
+	ColdStartRequested time.Time
+	ColdStartServed    time.Time
+}
+
+func (s Stats) TimeToRevisionReady() time.Duration {
+	return s.RevisionReady.Sub(s.Created)
+}
+
+func (s Stats) TimeToPodReady() time.Duration {
+	return s.PodReady.Sub(s.Created)
+}
+
+func (s Stats) TimeToFirstRequest() time.Duration {
+	return s.FirstRequest.Sub(s.Created)
+}
+
+func (s Stats) ColdStart() time.Duration {
+	return s.ColdStartServed.Sub(s.ColdStartRequested)
+}
+
+// Run creates the Services one after the other, waits for each of them to
+// serve a request and returns the Stats gathered for all of them, keyed by the
+// Services' names.
+func Run(ctx context.Context, kube kubernetes.Interface, dyn dynamic.Interface, client *http.Client, opts Options) (map[string]*Stats, error) {
+	runLabels := labels.Set{
+		pod.RunLabel: uuid.NewString(),
+	}
+
+	container, err := serviceContainer(opts.PodFn(opts.Namespace, ""))
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[string]*Stats, opts.Services)
+	for i := 0; i < opts.Services; i++ {
+		name := opts.Prefix + "-" + uuid.NewString()[:8]
+		s, err := runService(ctx, kube, dyn, client, opts, name, runLabels, container)
+		if err != nil {
+			return nil, err
+		}
+		stats[name] = s
+	}
+	return stats, nil
+}
+
+func runService(ctx context.Context, kube kubernetes.Interface, dyn dynamic.Interface, client *http.Client, opts Options, name string, runLabels labels.Set, container map[string]interface{}) (*Stats, error) {
+	services := dyn.Resource(ServiceResource).Namespace(opts.Namespace)
+	selector := labels.Set{serviceLabel: name}.String()
+
+	revisions, err := dyn.Resource(RevisionResource).Namespace(opts.Namespace).Watch(ctx, metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup watch for revisions: %w", err)
+	}
+	defer revisions.Stop()
+
+	pods, err := kube.CoreV1().Pods(opts.Namespace).Watch(ctx, metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup watch for pods: %w", err)
+	}
+	defer pods.Stop()
+
+	var (
+		mu    sync.Mutex
+		stats = &Stats{}
+	)
+	revisionReadyCh := make(chan struct{}, 1)
+	podReadyCh := make(chan struct{}, 1)
+	scaledToZeroCh := make(chan struct{}, 1)
+
+	go func() {
+		for event := range revisions.ResultChan() {
+			obj, ok := event.Object.(*unstructured.Unstructured)
+			if !ok || !isReady(obj) {
+				continue
+			}
+			mu.Lock()
+			if stats.RevisionReady.IsZero() {
+				stats.RevisionReady = time.Now()
+				revisionReadyCh <- struct{}{}
+			}
+			mu.Unlock()
+		}
+	}()
+
+	go func() {
+		live := make(map[string]bool)
+		for event := range pods.ResultChan() {
+			p, ok := event.Object.(*corev1.Pod)
+			if !ok {
+				continue
+			}
+			now := time.Now()
+			mu.Lock()
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				live[p.Name] = true
+				if pod.IsConditionTrue(p, corev1.PodReady) && stats.PodReady.IsZero() {
+					stats.PodReady = now
+					podReadyCh <- struct{}{}
+				}
+			case watch.Deleted:
+				delete(live, p.Name)
+				if len(live) == 0 && !stats.PodReady.IsZero() && stats.ScaledToZero.IsZero() {
+					stats.ScaledToZero = now
+					scaledToZeroCh <- struct{}{}
+				}
+			}
+			mu.Unlock()
+		}
+	}()
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "serving.knative.dev/v1",
+		"kind":       "Service",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": opts.Namespace,
+		},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{container},
+				},
+			},
+		},
+	}}
+	obj.SetLabels(runLabels)
+
+	created := time.Now()
+	mu.Lock()
+	stats.Created = created
+	mu.Unlock()
+	if _, err := services.Create(ctx, obj, metav1.CreateOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to create service: %w", err)
+	}
+
+	if err := waitFor(ctx, revisionReadyCh); err != nil {
+		return nil, fmt.Errorf("failed to wait for revision becoming ready: %w", err)
+	}
+	if err := waitFor(ctx, podReadyCh); err != nil {
+		return nil, fmt.Errorf("failed to wait for pod becoming ready: %w", err)
+	}
+
+	target, err := serviceURL(ctx, services, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := wait.PollImmediateUntil(10*time.Millisecond, func() (bool, error) {
+		return request(ctx, client, target, opts.Ingress) == nil, nil
+	}, ctx.Done()); err != nil {
+		return nil, fmt.Errorf("failed to wait for first successful request: %w", err)
+	}
+	mu.Lock()
+	stats.FirstRequest = time.Now()
+	mu.Unlock()
+
+	if opts.ScaleFromZero {
+		if err := waitFor(ctx, scaledToZeroCh); err != nil {
+			return nil, fmt.Errorf("failed to wait for scale to zero: %w", err)
+		}
+
+		requested := time.Now()
+		if err := wait.PollImmediateUntil(10*time.Millisecond, func() (bool, error) {
+			return request(ctx, client, target, opts.Ingress) == nil, nil
+		}, ctx.Done()); err != nil {
+			return nil, fmt.Errorf("failed to wait for successful request from zero: %w", err)
+		}
+		mu.Lock()
+		stats.ColdStartRequested = requested
+		stats.ColdStartServed = time.Now()
+		mu.Unlock()
+	}
+
+	if !opts.SkipDelete {
+		if err := services.Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+			return nil, fmt.Errorf("failed to delete service: %w", err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	copied := *stats
+	return &copied, nil
+}
+
+// serviceContainer turns the first container of the given pod into a
+// container that's accepted by Knative, dropping everything that Knative
+// either sets itself or doesn't allow.
+func serviceContainer(p *corev1.Pod) (map[string]interface{}, error) {
+	if len(p.Spec.Containers) == 0 {
+		return nil, errors.New("pod has no containers")
+	}
+	from := p.Spec.Containers[0]
+	c := corev1.Container{
+		Image:     from.Image,
+		Command:   from.Command,
+		Args:      from.Args,
+		Resources: from.Resources,
+	}
+	for _, env := range from.Env {
+		// These are reserved by Knative.
+		if env.Name == "PORT" || strings.HasPrefix(env.Name, "K_") {
+			continue
+		}
+		// Knative rejects fieldRefs unless explicitly enabled.
+		if env.ValueFrom != nil && env.ValueFrom.FieldRef != nil {
+			continue
+		}
+		c.Env = append(c.Env, env)
+	}
+	if len(from.Ports) > 0 {
+		c.Ports = []corev1.ContainerPort{{ContainerPort: from.Ports[0].ContainerPort}}
+	}
+
+	container, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert container: %w", err)
+	}
+	// The converter emits empty names, which Knative would otherwise reject.
+	delete(container, "name")
+	return container, nil
+}
+
+// serviceURL waits for the Service to report its URL and returns it.
+func serviceURL(ctx context.Context, services dynamic.ResourceInterface, name string) (*url.URL, error) {
+	var target *url.URL
+	if err := wait.PollImmediateUntil(100*time.Millisecond, func() (bool, error) {
+		got, err := services.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, fmt.Errorf("failed to fetch service: %w", err)
+		}
+		raw, _, err := unstructured.NestedString(got.Object, "status", "url")
+		if err != nil || raw == "" {
+			return false, nil
+		}
+		target, err = url.Parse(raw)
+		return err == nil, err
+	}, ctx.Done()); err != nil {
+		return nil, fmt.Errorf("failed to wait for service URL: %w", err)
+	}
+	return target, nil
+}
+
+// request sends a single request to the target and returns an error if it
+// wasn't successful.
+func request(ctx context.Context, client *http.Client, target *url.URL, ingress string) error {
+	u := *target
+	if ingress != "" {
+		u.Host = ingress
+		u.Scheme = "http"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Host = target.Host
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// isReady returns true if the given Knative object has a true Ready condition.
+func isReady(obj *unstructured.Unstructured) bool {
+	conds, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conds {
+		cond, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if cond["type"] == "Ready" && cond["status"] == string(corev1.ConditionTrue) {
+			return true
+		}
+	}
+	return false
+}
+
+func waitFor(ctx context.Context, ch chan struct{}) error {
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
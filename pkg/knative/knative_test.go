@@ -0,0 +1,159 @@
+package knative
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testNamespace = "test"
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	kube := fake.NewSimpleClientset()
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		ServiceResource:  "ServiceList",
+		RevisionResource: "RevisionList",
+	})
+	services, err := dyn.Resource(ServiceResource).Namespace(testNamespace).Watch(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to watch services: %v", err)
+	}
+	defer services.Stop()
+	go serve(ctx, t, kube, dyn, services, server.URL)
+
+	stats, err := Run(ctx, kube, dyn, server.Client(), Options{
+		Namespace: testNamespace,
+		Prefix:    "test",
+		PodFn: func(ns, name string) *corev1.Pod {
+			return &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name:  "user-container",
+				Image: "example.com/app",
+				Env:   []corev1.EnvVar{{Name: "PORT", Value: "8080"}, {Name: "KEEP", Value: "me"}},
+			}}}}
+		},
+		Services: 2,
+	})
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	if len(stats) != 2 {
+		t.Fatalf("len(stats) = %d, want 2", len(stats))
+	}
+	for name, s := range stats {
+		if s.TimeToRevisionReady() < 0 || s.TimeToPodReady() < 0 || s.TimeToFirstRequest() < s.TimeToPodReady() {
+			t.Errorf("stats of %s are out of order: %+v", name, s)
+		}
+		if s.Created.IsZero() || s.FirstRequest.IsZero() {
+			t.Errorf("stats of %s are incomplete: %+v", name, s)
+		}
+	}
+
+	left, err := dyn.Resource(ServiceResource).Namespace(testNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list services: %v", err)
+	}
+	if len(left.Items) != 0 {
+		t.Errorf("%d services were left behind", len(left.Items))
+	}
+}
+
+func TestServiceContainer(t *testing.T) {
+	got, err := serviceContainer(&corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+		Name:  "user-container",
+		Image: "example.com/app",
+		Env: []corev1.EnvVar{
+			{Name: "PORT", Value: "8080"},
+			{Name: "K_SERVICE", Value: "x"},
+			{Name: "POD_NAME", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}}},
+			{Name: "KEEP", Value: "me"},
+		},
+		Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+	}}}})
+	if err != nil {
+		t.Fatalf("serviceContainer() = %v", err)
+	}
+
+	if _, ok := got["name"]; ok {
+		t.Errorf("name = %v, want it to be dropped", got["name"])
+	}
+	if got["image"] != "example.com/app" {
+		t.Errorf("image = %v, want example.com/app", got["image"])
+	}
+	env, _, _ := unstructured.NestedSlice(got, "env")
+	if len(env) != 1 || env[0].(map[string]interface{})["name"] != "KEEP" {
+		t.Errorf("env = %v, want only KEEP", env)
+	}
+	ports, _, _ := unstructured.NestedSlice(got, "ports")
+	if len(ports) != 1 || ports[0].(map[string]interface{})["name"] != nil {
+		t.Errorf("ports = %v, want a single unnamed port", ports)
+	}
+}
+
+// serve plays Knative: it makes every Service seen by the watch ready with a
+// Revision and a ready pod, and serves it on the given URL.
+func serve(ctx context.Context, t *testing.T, kube kubernetes.Interface, dyn *dynamicfake.FakeDynamicClient, services watch.Interface, url string) {
+	for event := range services.ResultChan() {
+		if event.Type != watch.Added {
+			continue
+		}
+		svc := event.Object.(*unstructured.Unstructured)
+		name := svc.GetName()
+		labels := map[string]interface{}{serviceLabel: name}
+
+		revision := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "serving.knative.dev/v1",
+			"kind":       "Revision",
+			"metadata": map[string]interface{}{
+				"name":      name + "-00001",
+				"namespace": testNamespace,
+				"labels":    labels,
+			},
+			"status": map[string]interface{}{
+				"conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": "True"}},
+			},
+		}}
+		if _, err := dyn.Resource(RevisionResource).Namespace(testNamespace).Create(ctx, revision, metav1.CreateOptions{}); err != nil {
+			t.Errorf("failed to create revision: %v", err)
+			return
+		}
+
+		if _, err := kube.CoreV1().Pods(testNamespace).Create(ctx, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name + "-pod", Namespace: testNamespace, Labels: map[string]string{serviceLabel: name}},
+			Status: corev1.PodStatus{Conditions: []corev1.PodCondition{{
+				Type:   corev1.PodReady,
+				Status: corev1.ConditionTrue,
+			}}},
+		}, metav1.CreateOptions{}); err != nil {
+			t.Errorf("failed to create pod: %v", err)
+			return
+		}
+
+		if err := unstructured.SetNestedField(svc.Object, url, "status", "url"); err != nil {
+			t.Errorf("failed to set url: %v", err)
+			return
+		}
+		if _, err := dyn.Resource(ServiceResource).Namespace(testNamespace).Update(ctx, svc, metav1.UpdateOptions{}); err != nil {
+			t.Errorf("failed to update service: %v", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Type is the type of a metric family.
+type Type string
+
+const (
+	TypeCounter   Type = "counter"
+	TypeGauge     Type = "gauge"
+	TypeHistogram Type = "histogram"
+)
+
+// DefaultBuckets are the upper bounds of histogram buckets in seconds, covering
+// typical pod startup latencies.
+var DefaultBuckets = []float64{0.1, 0.25, 0.5, 1, 2, 3, 5, 7.5, 10, 15, 20, 30, 60, 120}
+
+// Labels are the labels of a single series.
+type Labels map[string]string
+
+// Sample is a single value of a series.
+type Sample struct {
+	Name   string
+	Labels Labels
+	Value  float64
+}
+
+// Registry holds metric families and renders them in the Prometheus text
+// exposition format. It's safe for concurrent use.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+type family struct {
+	name    string
+	help    string
+	typ     Type
+	buckets []float64
+	series  map[string]*series
+}
+
+type series struct {
+	labels Labels
+	value  float64
+	// Only set for histograms.
+	counts []uint64
+	count  uint64
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		families: make(map[string]*family),
+	}
+}
+
+// Add adds delta to the given counter.
+func (r *Registry) Add(name, help string, labels Labels, delta float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.series(name, help, TypeCounter, nil, labels).value += delta
+}
+
+// Set sets the given gauge to value.
+func (r *Registry) Set(name, help string, labels Labels, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.series(name, help, TypeGauge, nil, labels).value = value
+}
+
+// Observe adds value to the given histogram. The buckets are only used when
+// the histogram is first observed.
+func (r *Registry) Observe(name, help string, buckets []float64, labels Labels, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.series(name, help, TypeHistogram, buckets, labels)
+	for i, bound := range r.families[name].buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.value += value
+}
+
+func (r *Registry) series(name, help string, typ Type, buckets []float64, labels Labels) *series {
+	f := r.families[name]
+	if f == nil {
+		f = &family{
+			name:    name,
+			help:    help,
+			typ:     typ,
+			buckets: buckets,
+			series:  make(map[string]*series),
+		}
+		r.families[name] = f
+	}
+	key := formatLabels(labels)
+	s := f.series[key]
+	if s == nil {
+		s = &series{labels: labels}
+		if typ == TypeHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Samples returns all samples of the registry, with histograms broken up into
+// their _bucket, _sum and _count series.
+func (r *Registry) Samples() []Sample {
+	var samples []Sample
+	r.each(func(f *family, _ string) {}, func(s Sample) {
+		samples = append(samples, s)
+	})
+	return samples
+}
+
+// WriteText writes all metric families in the Prometheus text exposition
+// format.
+func (r *Registry) WriteText(w io.Writer) error {
+	var err error
+	write := func(format string, args ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+	r.each(func(f *family, help string) {
+		write("# HELP %s %s\n", f.name, help)
+		write("# TYPE %s %s\n", f.name, f.typ)
+	}, func(s Sample) {
+		write("%s%s %s\n", s.Name, formatLabels(s.Labels), formatValue(s.Value))
+	})
+	return err
+}
+
+// Handler serves the registry in the Prometheus text exposition format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+// each calls familyFn for each family and sampleFn for each of its samples, in
+// a stable order.
+func (r *Registry) each(familyFn func(*family, string), sampleFn func(Sample)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := r.families[name]
+		familyFn(f, escapeHelp(f.help))
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			s := f.series[key]
+			if f.typ != TypeHistogram {
+				sampleFn(Sample{Name: name, Labels: s.labels, Value: s.value})
+				continue
+			}
+			for i, bound := range f.buckets {
+				sampleFn(Sample{Name: name + "_bucket", Labels: with(s.labels, "le", formatValue(bound)), Value: float64(s.counts[i])})
+			}
+			sampleFn(Sample{Name: name + "_bucket", Labels: with(s.labels, "le", "+Inf"), Value: float64(s.count)})
+			sampleFn(Sample{Name: name + "_sum", Labels: s.labels, Value: s.value})
+			sampleFn(Sample{Name: name + "_count", Labels: s.labels, Value: float64(s.count)})
+		}
+	}
+}
+
+// with returns a copy of the labels with the given label added.
+func with(labels Labels, name, value string) Labels {
+	copied := make(Labels, len(labels)+1)
+	for k, v := range labels {
+		copied[k] = v
+	}
+	copied[name] = value
+	return copied
+}
+
+func formatLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name+`="`+labelEscaper.Replace(labels[name])+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
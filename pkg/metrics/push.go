@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Push replaces the metrics of the given job and grouping labels on a
+// Prometheus Pushgateway with all metrics in the registry.
+func Push(ctx context.Context, client *http.Client, gateway, job string, grouping Labels, r *Registry) error {
+	target := strings.TrimSuffix(gateway, "/") + "/metrics/job/" + pathValue(job)
+	names := make([]string, 0, len(grouping))
+	for name := range grouping {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		target += "/" + name + "/" + pathValue(grouping[name])
+	}
+
+	var body bytes.Buffer
+	if err := r.WriteText(&body); err != nil {
+		return fmt.Errorf("failed to render metrics: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, &body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	return send(client, req)
+}
+
+// pathValue encodes a value for use in the path of the Pushgateway API.
+func pathValue(value string) string {
+	if value == "" || strings.Contains(value, "/") {
+		// Values with slashes (and empty ones) have to be base64 encoded.
+		return base64.RawURLEncoding.EncodeToString([]byte(value)) + "@base64"
+	}
+	return url.PathEscape(value)
+}
+
+// RemoteWrite sends all samples in the registry to a Prometheus remote-write
+// endpoint, with the given labels added to all of them.
+func RemoteWrite(ctx context.Context, client *http.Client, endpoint string, labels Labels, r *Registry, now time.Time) error {
+	var msg []byte
+	for _, s := range r.Samples() {
+		all := make(Labels, len(s.Labels)+len(labels)+1)
+		for k, v := range labels {
+			all[k] = v
+		}
+		for k, v := range s.Labels {
+			all[k] = v
+		}
+		all["__name__"] = s.Name
+		msg = protowire.AppendTag(msg, 1, protowire.BytesType)
+		msg = protowire.AppendBytes(msg, timeSeries(all, s.Value, now))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(snappy.Encode(nil, msg)))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	return send(client, req)
+}
+
+// timeSeries encodes a prometheus.TimeSeries with a single sample.
+func timeSeries(labels Labels, value float64, now time.Time) []byte {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	// Remote-write requires labels to be sorted by name.
+	sort.Strings(names)
+
+	var ts []byte
+	for _, name := range names {
+		var label []byte
+		label = protowire.AppendTag(label, 1, protowire.BytesType)
+		label = protowire.AppendString(label, name)
+		label = protowire.AppendTag(label, 2, protowire.BytesType)
+		label = protowire.AppendString(label, labels[name])
+		ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, label)
+	}
+
+	var sample []byte
+	sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(value))
+	sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+	sample = protowire.AppendVarint(sample, uint64(now.UnixNano()/int64(time.Millisecond)))
+	ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+	return protowire.AppendBytes(ts, sample)
+}
+
+// send sends the request and returns an error if it wasn't successful.
+func send(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send metrics: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to send metrics, got status code %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package metrics
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestPush(t *testing.T) {
+	var method, path, contentType, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		method, path, contentType, body = r.Method, r.URL.EscapedPath(), r.Header.Get("Content-Type"), string(b)
+	}))
+	defer server.Close()
+
+	r := NewRegistry()
+	r.Add("test_total", "A test counter.", Labels{"node": "node-1"}, 2)
+	grouping := Labels{"run": "a/b", "instance": "x y", "empty": ""}
+	if err := Push(context.Background(), server.Client(), server.URL+"/", "podspeed", grouping, r); err != nil {
+		t.Fatalf("Push() = %v", err)
+	}
+
+	if method != http.MethodPut {
+		t.Errorf("method = %s, want %s", method, http.MethodPut)
+	}
+	// Labels are sorted, values with slashes and empty ones are base64 encoded.
+	if want := "/metrics/job/podspeed/empty/@base64/instance/x%20y/run/YS9i@base64"; path != want {
+		t.Errorf("path = %s, want %s", path, want)
+	}
+	if !strings.HasPrefix(contentType, "text/plain") {
+		t.Errorf("content type = %s, want text/plain", contentType)
+	}
+	if want := `test_total{node="node-1"} 2`; !strings.Contains(body, want) {
+		t.Errorf("body doesn't contain %q:\n%s", want, body)
+	}
+}
+
+func TestPushError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no way", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	err := Push(context.Background(), server.Client(), server.URL, "podspeed", nil, NewRegistry())
+	if err == nil || !strings.Contains(err.Error(), "400: no way") {
+		t.Errorf("Push() = %v, want the status and body in the error", err)
+	}
+}
+
+func TestRemoteWrite(t *testing.T) {
+	var header http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	r := NewRegistry()
+	r.Set("test_seconds", "A test gauge.", Labels{"phase": "ready"}, 1.5)
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := RemoteWrite(context.Background(), server.Client(), server.URL, Labels{"run": "a", "phase": "overridden"}, r, now); err != nil {
+		t.Fatalf("RemoteWrite() = %v", err)
+	}
+
+	if header.Get("Content-Encoding") != "snappy" || header.Get("Content-Type") != "application/x-protobuf" {
+		t.Errorf("headers = %v, want snappy encoded protobuf", header)
+	}
+	msg, err := snappy.Decode(nil, body)
+	if err != nil {
+		t.Fatalf("failed to decode snappy body: %v", err)
+	}
+	series := decodeWriteRequest(t, msg)
+	if len(series) != 1 {
+		t.Fatalf("got %d series, want 1", len(series))
+	}
+
+	got := series[0]
+	// The labels of the sample take precedence and all are sorted by name.
+	wantLabels := [][2]string{{"__name__", "test_seconds"}, {"phase", "ready"}, {"run", "a"}}
+	if len(got.labels) != len(wantLabels) {
+		t.Fatalf("labels = %v, want %v", got.labels, wantLabels)
+	}
+	for i := range wantLabels {
+		if got.labels[i] != wantLabels[i] {
+			t.Errorf("labels = %v, want %v", got.labels, wantLabels)
+			break
+		}
+	}
+	if got.value != 1.5 {
+		t.Errorf("value = %v, want 1.5", got.value)
+	}
+	if want := now.UnixNano() / int64(time.Millisecond); got.timestamp != want {
+		t.Errorf("timestamp = %d, want %d", got.timestamp, want)
+	}
+}
+
+type testSeries struct {
+	labels    [][2]string
+	value     float64
+	timestamp int64
+}
+
+// decodeWriteRequest decodes the time series of a prometheus.WriteRequest.
+func decodeWriteRequest(t *testing.T, msg []byte) []testSeries {
+	var series []testSeries
+	eachField(t, msg, func(num protowire.Number, ts []byte) {
+		if num != 1 {
+			return
+		}
+		var s testSeries
+		eachField(t, ts, func(num protowire.Number, field []byte) {
+			switch num {
+			case 1:
+				var label [2]string
+				eachField(t, field, func(num protowire.Number, v []byte) {
+					label[num-1] = string(v)
+				})
+				s.labels = append(s.labels, label)
+			case 2:
+				eachField(t, field, func(num protowire.Number, v []byte) {
+					switch num {
+					case 1:
+						bits, _ := protowire.ConsumeFixed64(v)
+						s.value = math.Float64frombits(bits)
+					case 2:
+						ms, _ := protowire.ConsumeVarint(v)
+						s.timestamp = int64(ms)
+					}
+				})
+			}
+		})
+		series = append(series, s)
+	})
+	return series
+}
+
+// eachField calls fn with the number and raw value of every field in msg.
+// Length-delimited values are passed without their length prefix.
+func eachField(t *testing.T, msg []byte, fn func(protowire.Number, []byte)) {
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			t.Fatalf("failed to decode tag: %v", protowire.ParseError(n))
+		}
+		msg = msg[n:]
+		n = protowire.ConsumeFieldValue(num, typ, msg)
+		if n < 0 {
+			t.Fatalf("failed to decode field %d: %v", num, protowire.ParseError(n))
+		}
+		value := msg[:n]
+		if typ == protowire.BytesType {
+			value, _ = protowire.ConsumeBytes(value)
+		}
+		fn(num, value)
+		msg = msg[n:]
+	}
+}
@@ -0,0 +1,158 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/benchmark"
+	"github.com/markusthoemmes/podspeed/pkg/metrics"
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	phaseMetric       = "podspeed_pod_phase_duration_seconds"
+	startupsMetric    = "podspeed_pod_startups_total"
+	failuresMetric    = "podspeed_pod_startup_failures_total"
+	lastSuccessMetric = "podspeed_last_success_timestamp_seconds"
+
+	// reasonTimeout is the failure reason of pods that didn't fail visibly but
+	// also didn't finish in time.
+	reasonTimeout = "Timeout"
+	// reasonError is the failure reason of measurements that failed for any
+	// other reason, i.e. API errors.
+	reasonError = "Error"
+)
+
+// phase is a single phase of a pod's startup that is exported as a histogram.
+type phase struct {
+	name string
+	fn   func(pod.Stats) time.Duration
+}
+
+// Options configures a Monitor.
+type Options struct {
+	// Benchmark configures each measurement. Only a single pod is created per
+	// measurement.
+	Benchmark benchmark.Options
+	// Interval is the time between the start of two measurements.
+	Interval time.Duration
+	// Timeout is the time a single measurement may take before it's
+	// considered failed.
+	Timeout time.Duration
+}
+
+// Monitor continuously measures the startup of pods and records the results
+// as metrics.
+type Monitor struct {
+	kube     kubernetes.Interface
+	opts     Options
+	phases   []phase
+	registry *metrics.Registry
+}
+
+func New(kube kubernetes.Interface, opts Options) *Monitor {
+	opts.Benchmark.Pods = 1
+	phases := []phase{
+		{name: "scheduled", fn: pod.Stats.TimeToScheduled},
+		{name: "initialized", fn: pod.Stats.TimeToInitialized},
+		{name: "containers_started", fn: pod.Stats.TimeToContainersStarted},
+		{name: "ip", fn: pod.Stats.TimeToIP},
+		{name: "ready", fn: pod.Stats.TimeToReady},
+	}
+	if opts.Benchmark.Probe {
+		phases = append(phases, phase{name: "probed", fn: pod.Stats.TimeToProbed})
+	}
+	return &Monitor{
+		kube:     kube,
+		opts:     opts,
+		phases:   phases,
+		registry: metrics.NewRegistry(),
+	}
+}
+
+// Registry returns the registry the metrics are recorded in.
+func (m *Monitor) Registry() *metrics.Registry {
+	return m.registry
+}
+
+// Run measures a pod every interval until the context is done.
+func (m *Monitor) Run(ctx context.Context) {
+	wait.NonSlidingUntilWithContext(ctx, m.measure, m.opts.Interval)
+}
+
+// measure runs a single measurement and records its outcome.
+func (m *Monitor) measure(ctx context.Context) {
+	opts := m.opts.Benchmark
+	opts.Tracker = pod.NewTracker()
+
+	runCtx, cancel := context.WithTimeout(ctx, m.opts.Timeout)
+	defer cancel()
+	go cancelOnFailure(runCtx, cancel, opts.Tracker)
+
+	result, err := benchmark.Run(runCtx, m.kube, opts)
+	if ctx.Err() != nil {
+		// Shutting down, the measurement is incomplete.
+		return
+	}
+	if err != nil {
+		reason, node := reasonError, ""
+		if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+			reason = reasonTimeout
+		}
+		for _, s := range opts.Tracker.Stats() {
+			if s.FailureReason != "" {
+				reason = s.FailureReason
+			}
+			node = s.Node
+		}
+		log.Printf("Measurement failed with reason %s: %v", reason, err)
+		m.registry.Add(failuresMetric, "The amount of pods that failed to start.",
+			metrics.Labels{"reason": reason, "node": node}, 1)
+		m.cleanup(ctx, opts.Tracker)
+		return
+	}
+
+	for _, s := range result.Stats {
+		for _, p := range m.phases {
+			if d := p.fn(*s); d >= 0 {
+				m.registry.Observe(phaseMetric, "The time it took pods to reach each phase of their startup.",
+					metrics.DefaultBuckets, metrics.Labels{"phase": p.name}, d.Seconds())
+			}
+		}
+		m.registry.Add(startupsMetric, "The amount of pods that started successfully.",
+			metrics.Labels{"node": s.Node}, 1)
+	}
+	m.registry.Set(lastSuccessMetric, "The time of the last successful measurement.",
+		nil, float64(time.Now().Unix()))
+}
+
+// cleanup deletes the pods of a failed measurement.
+func (m *Monitor) cleanup(ctx context.Context, tracker *pod.Tracker) {
+	var zero int64
+	for name := range tracker.Stats() {
+		err := m.kube.CoreV1().Pods(m.opts.Benchmark.Namespace).Delete(ctx, name, metav1.DeleteOptions{
+			GracePeriodSeconds: &zero,
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			log.Printf("Failed to delete pod %s: %v", name, err)
+		}
+	}
+}
+
+// cancelOnFailure cancels the measurement as soon as a pod failed visibly,
+// rather than waiting for the timeout.
+func cancelOnFailure(ctx context.Context, cancel context.CancelFunc, tracker *pod.Tracker) {
+	wait.UntilWithContext(ctx, func(context.Context) {
+		for _, s := range tracker.Stats() {
+			if !s.Failed.IsZero() {
+				cancel()
+			}
+		}
+	}, time.Second)
+}
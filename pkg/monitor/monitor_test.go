@@ -0,0 +1,129 @@
+package monitor
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/benchmark"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testNamespace = "test"
+
+func TestMeasure(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	kube := fake.NewSimpleClientset()
+	pods, err := kube.CoreV1().Pods(testNamespace).Watch(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to watch pods: %v", err)
+	}
+	defer pods.Stop()
+	go startPods(ctx, t, kube, pods)
+
+	m := New(kube, Options{Benchmark: benchmarkOptions(), Timeout: 5 * time.Second})
+	m.measure(ctx)
+
+	got := scrape(t, m)
+	for _, want := range []string{
+		`podspeed_pod_startups_total{node="node-1"} 1`,
+		`podspeed_pod_phase_duration_seconds_count{phase="ready"} 1`,
+		`podspeed_pod_phase_duration_seconds_count{phase="scheduled"} 1`,
+		"podspeed_last_success_timestamp_seconds ",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("metrics don't contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, failuresMetric) {
+		t.Errorf("metrics contain failures:\n%s", got)
+	}
+}
+
+func TestMeasureTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Nothing ever starts the pod.
+	kube := fake.NewSimpleClientset()
+	m := New(kube, Options{Benchmark: benchmarkOptions(), Timeout: 100 * time.Millisecond})
+	m.measure(ctx)
+
+	got := scrape(t, m)
+	if want := `podspeed_pod_startup_failures_total{node="",reason="Timeout"} 1`; !strings.Contains(got, want) {
+		t.Errorf("metrics don't contain %q:\n%s", want, got)
+	}
+	if strings.Contains(got, startupsMetric) {
+		t.Errorf("metrics contain startups:\n%s", got)
+	}
+
+	left, err := kube.CoreV1().Pods(testNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list pods: %v", err)
+	}
+	if len(left.Items) != 0 {
+		t.Errorf("%d pods were left behind", len(left.Items))
+	}
+}
+
+func benchmarkOptions() benchmark.Options {
+	return benchmark.Options{
+		Namespace: testNamespace,
+		Prefix:    "test",
+		PodFn: func(ns, name string) *corev1.Pod {
+			return &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "test", Image: "example.com/app"}}},
+			}
+		},
+	}
+}
+
+// scrape fetches the metrics of the monitor via HTTP.
+func scrape(t *testing.T, m *Monitor) string {
+	server := httptest.NewServer(m.Registry().Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("failed to scrape metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read metrics: %v", err)
+	}
+	return string(body)
+}
+
+// startPods plays the kubelet: it schedules every pod seen by the watch and
+// makes it ready right away.
+func startPods(ctx context.Context, t *testing.T, kube kubernetes.Interface, pods watch.Interface) {
+	for event := range pods.ResultChan() {
+		p, ok := event.Object.(*corev1.Pod)
+		if !ok || event.Type != watch.Added {
+			continue
+		}
+		p = p.DeepCopy()
+		p.Spec.NodeName = "node-1"
+		p.Status.Phase = corev1.PodRunning
+		p.Status.PodIP = "10.0.0.1"
+		for _, typ := range []corev1.PodConditionType{corev1.PodScheduled, corev1.PodInitialized, corev1.ContainersReady, corev1.PodReady} {
+			p.Status.Conditions = append(p.Status.Conditions, corev1.PodCondition{Type: typ, Status: corev1.ConditionTrue})
+		}
+		if _, err := kube.CoreV1().Pods(p.Namespace).UpdateStatus(ctx, p, metav1.UpdateOptions{}); err != nil {
+			t.Errorf("failed to start pod: %v", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package namespace
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Prefix is prepended to the names of the namespaces created for runs.
+const Prefix = "podspeed-"
+
+// Create creates a fresh namespace for a single run with the given labels and
+// returns its name. It's labelled with pod.RunLabel like all other objects
+// podspeed creates.
+func Create(ctx context.Context, kube kubernetes.Interface, labels map[string]string) (string, error) {
+	id := uuid.NewString()
+	nsLabels := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		nsLabels[k] = v
+	}
+	nsLabels[pod.RunLabel] = id
+
+	ns, err := kube.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   Prefix + id,
+			Labels: nsLabels,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to create namespace: %w", err)
+	}
+	return ns.Name, nil
+}
+
+// Delete deletes the namespace and waits for it to be gone, which is only
+// the case once everything in it is deleted as well.
+func Delete(ctx context.Context, kube kubernetes.Interface, name string) error {
+	if err := kube.CoreV1().Namespaces().Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete namespace %q: %w", name, err)
+	}
+	if err := wait.PollImmediateUntil(time.Second, func() (bool, error) {
+		_, err := kube.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}, ctx.Done()); err != nil {
+		return fmt.Errorf("failed to wait for namespace %q to be deleted: %w", name, err)
+	}
+	return nil
+}
+
+// Select returns the names of all existing namespaces matching the given label
+// selector, sorted by name.
+func Select(ctx context.Context, kube kubernetes.Interface, selector string) ([]string, error) {
+	list, err := kube.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	names := make([]string, 0, len(list.Items))
+	for _, ns := range list.Items {
+		if ns.DeletionTimestamp == nil {
+			names = append(names, ns.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
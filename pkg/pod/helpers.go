@@ -6,6 +6,10 @@ import (
 	corev1 "k8s.io/api/core/v1"
 )
 
+// RunLabel is put on all objects podspeed creates, with the ID of the run as
+// value.
+const RunLabel = "podspeed/run"
+
 func IsConditionTrue(p *corev1.Pod, condType corev1.PodConditionType) bool {
 	for _, cond := range p.Status.Conditions {
 		if cond.Type == condType && cond.Status == corev1.ConditionTrue {
@@ -15,12 +19,41 @@ func IsConditionTrue(p *corev1.Pod, condType corev1.PodConditionType) bool {
 	return false
 }
 
+// LastContainerStartedTime returns when the last of the pod's running
+// containers started. Containers that aren't running are skipped.
 func LastContainerStartedTime(p *corev1.Pod) time.Time {
 	var last time.Time
 	for _, cond := range p.Status.ContainerStatuses {
-		if last.Before(cond.State.Running.StartedAt.Time) {
+		if cond.State.Running != nil && last.Before(cond.State.Running.StartedAt.Time) {
 			last = cond.State.Running.StartedAt.Time
 		}
 	}
 	return last
 }
+
+// failureReasons are reasons of waiting containers that won't resolve without
+// intervention.
+var failureReasons = map[string]bool{
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CrashLoopBackOff":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+}
+
+// FailureReason returns why the pod failed, or an empty string if it didn't.
+func FailureReason(p *corev1.Pod) string {
+	if p.Status.Phase == corev1.PodFailed {
+		if p.Status.Reason != "" {
+			return p.Status.Reason
+		}
+		return string(corev1.PodFailed)
+	}
+	for _, status := range append(append([]corev1.ContainerStatus{}, p.Status.InitContainerStatuses...), p.Status.ContainerStatuses...) {
+		if status.State.Waiting != nil && failureReasons[status.State.Waiting.Reason] {
+			return status.State.Waiting.Reason
+		}
+	}
+	return ""
+}
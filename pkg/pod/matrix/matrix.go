@@ -0,0 +1,282 @@
+package matrix
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Axis is a single dimension of a matrix, i.e. one property of the pod that is
+// varied over the given values.
+type Axis struct {
+	Name   string
+	Values []string
+}
+
+// Variant is a single combination of axis values.
+type Variant struct {
+	// Name describes the axis values of the variant, i.e. "env=10,cpu=100m".
+	Name string
+	// PodFn constructs the pods of this variant.
+	PodFn func(string, string) *corev1.Pod
+}
+
+// mutators maps the supported axis names to functions applying a value to a pod.
+var mutators = map[string]func(*corev1.Pod, string) error{
+	"containers": setContainers,
+	"env":        setEnv,
+	"volumes":    setVolumes,
+	"cpu":        setCPU,
+	"probe":      setProbe,
+}
+
+// AxisNames returns the names of all supported axes.
+func AxisNames() []string {
+	names := make([]string, 0, len(mutators))
+	for name := range mutators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseAxis parses an axis in the form of "name=value1,value2".
+func ParseAxis(s string) (Axis, error) {
+	pos := strings.IndexByte(s, '=')
+	if pos < 1 || pos == len(s)-1 {
+		return Axis{}, fmt.Errorf("axis %q must be of the form name=value1,value2", s)
+	}
+	axis := Axis{
+		Name:   s[:pos],
+		Values: strings.Split(s[pos+1:], ","),
+	}
+	if _, ok := mutators[axis.Name]; !ok {
+		return Axis{}, fmt.Errorf("unsupported axis %q, supported values: %s", axis.Name, strings.Join(AxisNames(), ", "))
+	}
+	return axis, nil
+}
+
+// Variants generates all combinations of the given axes on top of the base
+// constructor. The values are validated eagerly and each axis may only be
+// given once.
+func Variants(base func(string, string) *corev1.Pod, axes []Axis) ([]Variant, error) {
+	seen := make(map[string]bool, len(axes))
+	for _, axis := range axes {
+		if seen[axis.Name] {
+			return nil, fmt.Errorf("axis %q is given more than once", axis.Name)
+		}
+		seen[axis.Name] = true
+	}
+
+	combinations := [][]string{{}}
+	for _, axis := range axes {
+		next := make([][]string, 0, len(combinations)*len(axis.Values))
+		for _, combination := range combinations {
+			for _, value := range axis.Values {
+				c := make([]string, len(combination), len(combination)+1)
+				copy(c, combination)
+				next = append(next, append(c, value))
+			}
+		}
+		combinations = next
+	}
+
+	variants := make([]Variant, 0, len(combinations))
+	for _, combination := range combinations {
+		combination := combination
+		names := make([]string, 0, len(combination))
+		for i, value := range combination {
+			names = append(names, axes[i].Name+"="+value)
+		}
+
+		// Build a pod once to surface invalid values before running anything.
+		if err := apply(base("", ""), axes, combination); err != nil {
+			return nil, err
+		}
+
+		variants = append(variants, Variant{
+			Name: strings.Join(names, ","),
+			PodFn: func(ns, name string) *corev1.Pod {
+				p := base(ns, name)
+				// Errors have been ruled out above.
+				_ = apply(p, axes, combination)
+				return p
+			},
+		})
+	}
+	return variants, nil
+}
+
+func apply(p *corev1.Pod, axes []Axis, values []string) error {
+	for i, value := range values {
+		if err := mutators[axes[i].Name](p, value); err != nil {
+			return fmt.Errorf("invalid value %q for axis %q: %w", value, axes[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// setContainers duplicates the first container until the pod has n containers.
+// The duplicates get no ports or probes and a distinct PORT to listen on.
+func setContainers(p *corev1.Pod, value string) error {
+	n, err := parseCount(value)
+	if err != nil {
+		return err
+	}
+	if n < 1 {
+		return fmt.Errorf("must be at least 1")
+	}
+	if len(p.Spec.Containers) == 0 {
+		return fmt.Errorf("pod has no containers")
+	}
+
+	first := p.Spec.Containers[0]
+	containers := make([]corev1.Container, 0, n)
+	containers = append(containers, first)
+	for i := 1; i < n; i++ {
+		c := *first.DeepCopy()
+		c.Name = fmt.Sprintf("%s-%d", first.Name, i)
+		c.Ports = nil
+		c.ReadinessProbe = nil
+		c.LivenessProbe = nil
+		c.StartupProbe = nil
+		c.Env = setPort(c.Env, 9000+i)
+		containers = append(containers, c)
+	}
+	p.Spec.Containers = containers
+	return nil
+}
+
+// setPort sets PORT to the given port, replacing an existing entry if any.
+func setPort(env []corev1.EnvVar, port int) []corev1.EnvVar {
+	v := corev1.EnvVar{Name: "PORT", Value: strconv.Itoa(port)}
+	for i := range env {
+		if env[i].Name == v.Name {
+			env[i] = v
+			return env
+		}
+	}
+	return append(env, v)
+}
+
+// setEnv adds n environment variables to every container.
+func setEnv(p *corev1.Pod, value string) error {
+	n, err := parseCount(value)
+	if err != nil {
+		return err
+	}
+	for i := range p.Spec.Containers {
+		c := &p.Spec.Containers[i]
+		for j := 0; j < n; j++ {
+			c.Env = append(c.Env, corev1.EnvVar{
+				Name:  fmt.Sprintf("PODSPEED_ENV_%d", j),
+				Value: strconv.Itoa(j),
+			})
+		}
+	}
+	return nil
+}
+
+// setVolumes adds n emptyDir volumes and mounts them into the first container.
+func setVolumes(p *corev1.Pod, value string) error {
+	n, err := parseCount(value)
+	if err != nil {
+		return err
+	}
+	if n > 0 && len(p.Spec.Containers) == 0 {
+		return fmt.Errorf("pod has no containers")
+	}
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("podspeed-volume-%d", i)
+		p.Spec.Volumes = append(p.Spec.Volumes, corev1.Volume{
+			Name: name,
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		})
+		p.Spec.Containers[0].VolumeMounts = append(p.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      name,
+			MountPath: "/podspeed/" + name,
+		})
+	}
+	return nil
+}
+
+// setCPU sets the CPU request of every container.
+func setCPU(p *corev1.Pod, value string) error {
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return err
+	}
+	for i := range p.Spec.Containers {
+		c := &p.Spec.Containers[i]
+		if c.Resources.Requests == nil {
+			c.Resources.Requests = corev1.ResourceList{}
+		}
+		c.Resources.Requests[corev1.ResourceCPU] = quantity
+		if limit, ok := c.Resources.Limits[corev1.ResourceCPU]; ok && limit.Cmp(quantity) < 0 {
+			c.Resources.Limits[corev1.ResourceCPU] = quantity
+		}
+	}
+	return nil
+}
+
+// setProbe replaces the probes of the first container. Supported values are
+// "none", "readiness", "startup" and "readiness-startup". The probes hit the
+// first port of the container via HTTP.
+func setProbe(p *corev1.Pod, value string) error {
+	if len(p.Spec.Containers) == 0 {
+		return fmt.Errorf("pod has no containers")
+	}
+	c := &p.Spec.Containers[0]
+
+	port := intstr.FromInt(8080)
+	if len(c.Ports) > 0 {
+		port = intstr.FromInt(int(c.Ports[0].ContainerPort))
+	}
+	probe := func() *corev1.Probe {
+		return &corev1.Probe{
+			Handler: corev1.Handler{
+				HTTPGet: &corev1.HTTPGetAction{
+					Path: "/",
+					Port: port,
+				},
+			},
+			PeriodSeconds:    1,
+			SuccessThreshold: 1,
+			TimeoutSeconds:   1,
+		}
+	}
+
+	c.ReadinessProbe = nil
+	c.StartupProbe = nil
+	switch value {
+	case "none":
+	case "readiness":
+		c.ReadinessProbe = probe()
+	case "startup":
+		c.StartupProbe = probe()
+	case "readiness-startup":
+		c.ReadinessProbe = probe()
+		c.StartupProbe = probe()
+	default:
+		return fmt.Errorf("must be one of none, readiness, startup, readiness-startup")
+	}
+	return nil
+}
+
+func parseCount(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("must not be negative")
+	}
+	return n, nil
+}
@@ -0,0 +1,186 @@
+package matrix
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func basePod(ns, name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "app",
+				Image: "example.com/app",
+				Ports: []corev1.ContainerPort{{ContainerPort: 8080}},
+				Env:   []corev1.EnvVar{{Name: "PORT", Value: "8080"}},
+			}},
+		},
+	}
+}
+
+func TestParseAxis(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Axis
+		wantErr string
+	}{{
+		in:   "env=0,10",
+		want: Axis{Name: "env", Values: []string{"0", "10"}},
+	}, {
+		in:   "cpu=100m",
+		want: Axis{Name: "cpu", Values: []string{"100m"}},
+	}, {
+		in:      "env",
+		wantErr: "must be of the form",
+	}, {
+		in:      "=1",
+		wantErr: "must be of the form",
+	}, {
+		in:      "env=",
+		wantErr: "must be of the form",
+	}, {
+		in:      "memory=1Gi",
+		wantErr: `unsupported axis "memory"`,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			got, err := ParseAxis(test.in)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("ParseAxis() = %v, want an error containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseAxis() = %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseAxis() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestVariants(t *testing.T) {
+	tests := []struct {
+		name    string
+		axes    []Axis
+		want    []string
+		wantErr string
+	}{{
+		name: "no axes",
+		want: []string{""},
+	}, {
+		name: "all combinations",
+		axes: []Axis{{Name: "env", Values: []string{"0", "10"}}, {Name: "cpu", Values: []string{"100m", "1"}}},
+		want: []string{"env=0,cpu=100m", "env=0,cpu=1", "env=10,cpu=100m", "env=10,cpu=1"},
+	}, {
+		name:    "invalid value",
+		axes:    []Axis{{Name: "containers", Values: []string{"1", "0"}}},
+		wantErr: `invalid value "0" for axis "containers"`,
+	}, {
+		name:    "duplicate axis",
+		axes:    []Axis{{Name: "env", Values: []string{"0"}}, {Name: "cpu", Values: []string{"1"}}, {Name: "env", Values: []string{"10"}}},
+		wantErr: `axis "env" is given more than once`,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			variants, err := Variants(basePod, test.axes)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("Variants() = %v, want an error containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Variants() = %v", err)
+			}
+			got := make([]string, 0, len(variants))
+			for _, v := range variants {
+				got = append(got, v.Name)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("variants = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestVariantPods(t *testing.T) {
+	tests := []struct {
+		name  string
+		axis  Axis
+		check func(*testing.T, *corev1.Pod)
+	}{{
+		name: "containers",
+		axis: Axis{Name: "containers", Values: []string{"3"}},
+		check: func(t *testing.T, p *corev1.Pod) {
+			if len(p.Spec.Containers) != 3 {
+				t.Fatalf("got %d containers, want 3", len(p.Spec.Containers))
+			}
+			c := p.Spec.Containers[2]
+			if c.Name != "app-2" || c.Ports != nil || !reflect.DeepEqual(c.Env, []corev1.EnvVar{{Name: "PORT", Value: "9002"}}) {
+				t.Errorf("duplicate = %+v, want a distinct name and port", c)
+			}
+		},
+	}, {
+		name: "env",
+		axis: Axis{Name: "env", Values: []string{"2"}},
+		check: func(t *testing.T, p *corev1.Pod) {
+			if got := len(p.Spec.Containers[0].Env); got != 3 {
+				t.Errorf("got %d env vars, want 3", got)
+			}
+		},
+	}, {
+		name: "volumes",
+		axis: Axis{Name: "volumes", Values: []string{"2"}},
+		check: func(t *testing.T, p *corev1.Pod) {
+			if len(p.Spec.Volumes) != 2 || len(p.Spec.Containers[0].VolumeMounts) != 2 {
+				t.Errorf("got %d volumes and %d mounts, want 2 each", len(p.Spec.Volumes), len(p.Spec.Containers[0].VolumeMounts))
+			}
+		},
+	}, {
+		name: "cpu",
+		axis: Axis{Name: "cpu", Values: []string{"250m"}},
+		check: func(t *testing.T, p *corev1.Pod) {
+			got := p.Spec.Containers[0].Resources.Requests[corev1.ResourceCPU]
+			if want := resource.MustParse("250m"); got.Cmp(want) != 0 {
+				t.Errorf("cpu request = %s, want %s", got.String(), want.String())
+			}
+		},
+	}, {
+		name: "probe",
+		axis: Axis{Name: "probe", Values: []string{"readiness-startup"}},
+		check: func(t *testing.T, p *corev1.Pod) {
+			c := p.Spec.Containers[0]
+			if c.ReadinessProbe == nil || c.StartupProbe == nil {
+				t.Fatalf("probes = %v, %v, want both", c.ReadinessProbe, c.StartupProbe)
+			}
+			if port := c.ReadinessProbe.HTTPGet.Port.IntValue(); port != 8080 {
+				t.Errorf("probe port = %d, want 8080", port)
+			}
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			variants, err := Variants(basePod, []Axis{test.axis})
+			if err != nil {
+				t.Fatalf("Variants() = %v", err)
+			}
+			p := variants[0].PodFn("ns", "pod")
+			if p.Namespace != "ns" || p.Name != "pod" {
+				t.Errorf("pod = %s/%s, want ns/pod", p.Namespace, p.Name)
+			}
+			test.check(t, p)
+		})
+	}
+}
@@ -1,39 +1,156 @@
 package pod
 
-import "time"
+import (
+	"math"
+	"time"
+)
+
+// Unknown is returned as the duration between two points in time if either of
+// them wasn't observed. It's excluded from all statistics.
+const Unknown time.Duration = math.MinInt64
+
+// between returns the duration from one point in time to another, or Unknown
+// if either is zero.
+func between(from, to time.Time) time.Duration {
+	if from.IsZero() || to.IsZero() {
+		return Unknown
+	}
+	return to.Sub(from)
+}
 
 type Stats struct {
+	// Namespace is the namespace of the pod.
+	Namespace string
+	// IP is the IP address of the pod.
+	IP string
+	// Node is the name of the node the pod was scheduled to.
+	Node string
+
+	// Requested is when the pod was requested indirectly, i.e. by creating its
+	// controller. It's zero for bare pods.
+	Requested time.Time
+
+	// CreateStarted and CreateFinished are when the request creating the pod
+	// was sent and answered. They're zero for pods created by a controller.
+	CreateStarted  time.Time
+	CreateFinished time.Time
+	// DryRunStarted and DryRunFinished are the same for submitting the pod in
+	// dry-run mode right before creating it, if enabled.
+	DryRunStarted  time.Time
+	DryRunFinished time.Time
+
 	Created           time.Time
 	Scheduled         time.Time
+	Running           time.Time
 	Initialized       time.Time
 	ContainersStarted time.Time
 	ContainersReady   time.Time
 	Ready             time.Time
+	Completed         time.Time
+	Deleted           time.Time
+
+	// Failed is when the pod was first seen failing, for the given reason.
+	Failed        time.Time
+	FailureReason string
+
+	HasIP         time.Time
+	Probed        time.Time
+	EndpointReady time.Time
+	ServiceProbed time.Time
+	// CallbackReceived is when the application reported back to podspeed.
+	CallbackReceived time.Time
+
+	// PullStarted and PullFinished are when the first image pull of the pod
+	// started and the last one finished, as seen via Kubernetes Events. They're
+	// zero if no image had to be pulled.
+	PullStarted  time.Time
+	PullFinished time.Time
 
-	HasIP  time.Time
-	Probed time.Time
+	// Timestamps reported by the application itself. They are taken with the
+	// clock of the node the pod runs on.
+	ProcessStarted time.Time
+	ListenerBound  time.Time
+	FirstRequest   time.Time
+}
+
+func (s Stats) TimeToCreated() time.Duration {
+	return between(s.Requested, s.Created)
+}
+
+// CreateLatency is the time the API server took to answer the request
+// creating the pod.
+func (s Stats) CreateLatency() time.Duration {
+	return between(s.CreateStarted, s.CreateFinished)
+}
+
+// DryRunLatency is the time the API server took to answer the request
+// creating the pod in dry-run mode, which runs admission but doesn't persist
+// the pod.
+func (s Stats) DryRunLatency() time.Duration {
+	return between(s.DryRunStarted, s.DryRunFinished)
 }
 
 func (s Stats) TimeToScheduled() time.Duration {
-	return s.Scheduled.Sub(s.Created)
+	return between(s.Created, s.Scheduled)
 }
 
 func (s Stats) TimeToInitialized() time.Duration {
-	return s.Initialized.Sub(s.Created)
+	return between(s.Created, s.Initialized)
 }
 
 func (s Stats) TimeToContainersStarted() time.Duration {
-	return s.ContainersStarted.Sub(s.Created)
+	return between(s.Created, s.ContainersStarted)
 }
 
 func (s Stats) TimeToReady() time.Duration {
-	return s.Ready.Sub(s.Created)
+	return between(s.Created, s.Ready)
+}
+
+func (s Stats) TimeFromRequestToReady() time.Duration {
+	return between(s.Requested, s.Ready)
+}
+
+func (s Stats) TimeToCompleted() time.Duration {
+	return between(s.Created, s.Completed)
 }
 
 func (s Stats) TimeToIP() time.Duration {
-	return s.HasIP.Sub(s.Created)
+	return between(s.Created, s.HasIP)
 }
 
 func (s Stats) TimeToProbed() time.Duration {
-	return s.Probed.Sub(s.Created)
+	return between(s.Created, s.Probed)
+}
+
+func (s Stats) TimeToEndpoint() time.Duration {
+	return between(s.Created, s.EndpointReady)
+}
+
+func (s Stats) TimeToServiceProbed() time.Duration {
+	return between(s.Created, s.ServiceProbed)
+}
+
+func (s Stats) TimeToCallback() time.Duration {
+	return between(s.Created, s.CallbackReceived)
+}
+
+func (s Stats) TimeToProcessStarted() time.Duration {
+	return between(s.Created, s.ProcessStarted)
+}
+
+func (s Stats) TimeToFirstRequest() time.Duration {
+	return between(s.Created, s.FirstRequest)
+}
+
+// RuntimeOverhead is the time from the container being started to the
+// application's process running. The kubelet reports the start of containers
+// with a precision of seconds only, so it can be up to a second too large.
+func (s Stats) RuntimeOverhead() time.Duration {
+	return between(s.ContainersStarted, s.ProcessStarted)
+}
+
+// InitTime is the time the application took to initialize, from its process
+// running to listening for requests.
+func (s Stats) InitTime() time.Duration {
+	return between(s.ProcessStarted, s.ListenerBound)
 }
@@ -1,34 +1,197 @@
 package template
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
 )
 
-func PodConstructorFromYAML(content io.Reader) (func(string, string) *corev1.Pod, error) {
-	pod := &corev1.Pod{}
+// Placeholders that are replaced in all documents of a template before the
+// objects are created. They can be used to reference companion objects by name.
+const (
+	// PlaceholderID is replaced with an ID that is unique per template instance.
+	PlaceholderID = "${PODSPEED_ID}"
+	// PlaceholderNamespace is replaced with the namespace the objects are created in.
+	PlaceholderNamespace = "${PODSPEED_NAMESPACE}"
+	// PlaceholderPod is replaced with the name of the pod. It is empty for objects
+	// that are shared across the whole run.
+	PlaceholderPod = "${PODSPEED_POD}"
+)
+
+// ScopeAnnotation controls whether a companion object is created once per run
+// (the default) or once per pod.
+const ScopeAnnotation = "podspeed/scope"
+
+// Scope defines how often a companion object is created.
+type Scope string
+
+const (
+	// ScopeRun companions are created once before the first pod and deleted
+	// after the last one.
+	ScopeRun Scope = "run"
+	// ScopePod companions are created before each pod and deleted with it.
+	ScopePod Scope = "pod"
+)
+
+// supportedKinds are the kinds that can be used as companion objects.
+var supportedKinds = map[string]bool{
+	"ConfigMap":             true,
+	"Secret":                true,
+	"Service":               true,
+	"PersistentVolumeClaim": true,
+}
+
+// Template is a pod template with optional companion objects.
+type Template struct {
+	pod        []byte
+	companions []companion
+}
+
+type companion struct {
+	scope Scope
+	raw   []byte
+}
+
+// Parse parses a YAML or JSON stream of one or more documents. Exactly one of
+// them must be a Pod, the others are companion objects.
+func Parse(content io.Reader) (*Template, error) {
+	t := &Template{}
 
 	decoder := yaml.NewYAMLOrJSONDecoder(content, 64)
-	if err := decoder.Decode(pod); err != nil {
-		return nil, fmt.Errorf("failed to decode YAML content: %w", err)
-	}
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to decode YAML content: %w", err)
+		}
+		if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+			// Skip empty documents.
+			continue
+		}
 
-	return func(ns, name string) *corev1.Pod {
-		pod := pod.DeepCopy()
+		var meta struct {
+			metav1.TypeMeta   `json:",inline"`
+			metav1.ObjectMeta `json:"metadata,omitempty"`
+		}
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			return nil, fmt.Errorf("failed to decode object metadata: %w", err)
+		}
 
-		// Reset metadata
-		pod.ObjectMeta = metav1.ObjectMeta{
-			Namespace: ns,
-			Name:      name,
+		switch {
+		case meta.Kind == "" || meta.Kind == "Pod":
+			if t.pod != nil {
+				return nil, errors.New("template must contain exactly one pod")
+			}
+			t.pod = raw
+		case supportedKinds[meta.Kind]:
+			scope := Scope(meta.Annotations[ScopeAnnotation])
+			if scope == "" {
+				scope = ScopeRun
+			}
+			if scope != ScopeRun && scope != ScopePod {
+				return nil, fmt.Errorf("invalid %s annotation %q on %s %q, must be %q or %q",
+					ScopeAnnotation, scope, meta.Kind, meta.Name, ScopeRun, ScopePod)
+			}
+			t.companions = append(t.companions, companion{scope: scope, raw: raw})
+		default:
+			return nil, fmt.Errorf("unsupported kind %q in template, supported companion kinds are ConfigMap, Secret, Service and PersistentVolumeClaim", meta.Kind)
 		}
+	}
+	if t.pod == nil {
+		return nil, errors.New("template must contain exactly one pod")
+	}
 
-		// Reset Status
-		pod.Status = corev1.PodStatus{}
+	// Instantiate everything once to surface errors early.
+	if _, err := t.newPod("", "", ""); err != nil {
+		return nil, err
+	}
+	for _, scope := range []Scope{ScopeRun, ScopePod} {
+		if _, err := t.Companions(scope, "", "", ""); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
 
+// PodConstructorFromYAML parses the given content via Parse and returns a
+// constructor for its pod. Companion objects are ignored.
+func PodConstructorFromYAML(content io.Reader) (func(string, string) *corev1.Pod, error) {
+	t, err := Parse(content)
+	if err != nil {
+		return nil, err
+	}
+	return t.PodConstructor(""), nil
+}
+
+// HasCompanions returns true if the template contains companion objects.
+func (t *Template) HasCompanions() bool {
+	return len(t.companions) > 0
+}
+
+// PodConstructor returns a constructor for the template's pod, using the given
+// ID to replace PlaceholderID.
+func (t *Template) PodConstructor(id string) func(string, string) *corev1.Pod {
+	return func(ns, name string) *corev1.Pod {
+		// Errors have been ruled out when parsing the template.
+		pod, _ := t.newPod(id, ns, name)
 		return pod
-	}, nil
+	}
+}
+
+func (t *Template) newPod(id, ns, name string) (*corev1.Pod, error) {
+	pod := &corev1.Pod{}
+	if err := json.Unmarshal(replace(t.pod, id, ns, name), pod); err != nil {
+		return nil, fmt.Errorf("failed to decode pod: %w", err)
+	}
+
+	// Reset metadata
+	pod.ObjectMeta = metav1.ObjectMeta{
+		Namespace: ns,
+		Name:      name,
+	}
+
+	// Reset Status
+	pod.Status = corev1.PodStatus{}
+
+	return pod, nil
+}
+
+// Companions returns the companion objects of the given scope, with all
+// placeholders replaced. The pod name is ignored for ScopeRun objects.
+func (t *Template) Companions(scope Scope, id, ns, pod string) ([]runtime.Object, error) {
+	if scope == ScopeRun {
+		pod = ""
+	}
+
+	var objs []runtime.Object
+	for _, c := range t.companions {
+		if c.scope != scope {
+			continue
+		}
+		obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(replace(c.raw, id, ns, pod), nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode companion object: %w", err)
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+func replace(raw []byte, id, ns, pod string) []byte {
+	return []byte(strings.NewReplacer(
+		PlaceholderID, id,
+		PlaceholderNamespace, ns,
+		PlaceholderPod, pod,
+	).Replace(string(raw)))
 }
@@ -0,0 +1,182 @@
+package template
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const testPod = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: exported
+  namespace: elsewhere
+  resourceVersion: "42"
+spec:
+  containers:
+  - name: app
+    image: example.com/app
+    env:
+    - name: CONFIG
+      value: config-${PODSPEED_ID}
+status:
+  phase: Running
+`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		run, pod   int
+		companions bool
+		wantErr    string
+	}{{
+		name:    "pod only",
+		content: testPod,
+	}, {
+		name:    "pod without kind",
+		content: "spec:\n  containers:\n  - name: app\n    image: example.com/app\n",
+	}, {
+		name: "companions",
+		content: testPod + `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config-${PODSPEED_ID}
+---
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: secret-${PODSPEED_POD}
+  annotations:
+    podspeed/scope: pod
+`,
+		run: 1, pod: 1, companions: true,
+	}, {
+		name:    "no pod",
+		content: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n",
+		wantErr: "exactly one pod",
+	}, {
+		name:    "two pods",
+		content: testPod + "---\n" + testPod,
+		wantErr: "exactly one pod",
+	}, {
+		name:    "unsupported kind",
+		content: testPod + "---\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\n",
+		wantErr: `unsupported kind "Deployment"`,
+	}, {
+		name:    "invalid scope",
+		content: testPod + "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n  annotations:\n    podspeed/scope: node\n",
+		wantErr: `invalid podspeed/scope annotation "node"`,
+	}, {
+		name:    "invalid yaml",
+		content: "spec: [",
+		wantErr: "failed to decode",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tmpl, err := Parse(strings.NewReader(test.content))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("Parse() = %v, want an error containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() = %v", err)
+			}
+			if tmpl.HasCompanions() != test.companions {
+				t.Errorf("HasCompanions() = %v, want %v", tmpl.HasCompanions(), test.companions)
+			}
+			for scope, want := range map[Scope]int{ScopeRun: test.run, ScopePod: test.pod} {
+				objs, err := tmpl.Companions(scope, "id", "ns", "pod")
+				if err != nil {
+					t.Fatalf("Companions(%s) = %v", scope, err)
+				}
+				if len(objs) != want {
+					t.Errorf("Companions(%s) returned %d objects, want %d", scope, len(objs), want)
+				}
+			}
+		})
+	}
+}
+
+func TestPodConstructor(t *testing.T) {
+	tmpl, err := Parse(strings.NewReader(testPod))
+	if err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+
+	p := tmpl.PodConstructor("abc")("ns", "pod-1")
+	// Metadata and status of exported pods are reset.
+	if p.Namespace != "ns" || p.Name != "pod-1" || p.ResourceVersion != "" {
+		t.Errorf("metadata = %+v, want only namespace ns and name pod-1", p.ObjectMeta)
+	}
+	if p.Status.Phase != "" {
+		t.Errorf("phase = %s, want it reset", p.Status.Phase)
+	}
+	if got := p.Spec.Containers[0].Env[0].Value; got != "config-abc" {
+		t.Errorf("env = %s, want the ID replaced", got)
+	}
+}
+
+func TestCompanions(t *testing.T) {
+	tmpl, err := Parse(strings.NewReader(testPod + `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: shared-${PODSPEED_ID}-${PODSPEED_POD}
+data:
+  namespace: ${PODSPEED_NAMESPACE}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: own-${PODSPEED_POD}
+  annotations:
+    podspeed/scope: pod
+`))
+	if err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+
+	tests := []struct {
+		scope     Scope
+		wantName  string
+		wantNSRef string
+	}{{
+		// The pod placeholder is empty for objects shared by all pods.
+		scope:     ScopeRun,
+		wantName:  "shared-abc-",
+		wantNSRef: "ns",
+	}, {
+		scope:    ScopePod,
+		wantName: "own-pod-1",
+	}}
+
+	for _, test := range tests {
+		t.Run(string(test.scope), func(t *testing.T) {
+			objs, err := tmpl.Companions(test.scope, "abc", "ns", "pod-1")
+			if err != nil {
+				t.Fatalf("Companions() = %v", err)
+			}
+			if len(objs) != 1 {
+				t.Fatalf("got %d objects, want 1", len(objs))
+			}
+			cm, ok := objs[0].(*corev1.ConfigMap)
+			if !ok {
+				t.Fatalf("got %T, want a ConfigMap", objs[0])
+			}
+			if cm.Name != test.wantName {
+				t.Errorf("name = %s, want %s", cm.Name, test.wantName)
+			}
+			if cm.Data["namespace"] != test.wantNSRef {
+				t.Errorf("namespace reference = %q, want %q", cm.Data["namespace"], test.wantNSRef)
+			}
+		})
+	}
+}
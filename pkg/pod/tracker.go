@@ -0,0 +1,149 @@
+package pod
+
+import (
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// Transitions signals which states a pod reached for the first time when
+// observing an event.
+type Transitions struct {
+	HasIP   bool
+	Ready   bool
+	Deleted bool
+}
+
+// Tracker records Stats for pods from the watch events observed for them.
+type Tracker struct {
+	mu    sync.Mutex
+	stats map[string]*Stats
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{
+		stats: make(map[string]*Stats),
+	}
+}
+
+// Observe updates the Stats of the pod in the event with the time the event
+// was received at.
+func (t *Tracker) Observe(typ watch.EventType, p *corev1.Pod, now time.Time) Transitions {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var trans Transitions
+	stats := t.stats[p.Name]
+	if stats == nil {
+		stats = &Stats{}
+		t.stats[p.Name] = stats
+	}
+	if typ == watch.Added && stats.Created.IsZero() {
+		stats.Created = now
+	}
+	if typ == watch.Deleted {
+		if stats.Deleted.IsZero() {
+			stats.Deleted = now
+		}
+		trans.Deleted = true
+		return trans
+	}
+
+	if p.Status.PodIP != "" && stats.HasIP.IsZero() {
+		stats.HasIP = now
+		stats.IP = p.Status.PodIP
+		trans.HasIP = true
+	}
+	if stats.Namespace == "" {
+		stats.Namespace = p.Namespace
+	}
+	if p.Spec.NodeName != "" && stats.Node == "" {
+		stats.Node = p.Spec.NodeName
+	}
+	if p.Status.Phase == corev1.PodRunning && stats.Running.IsZero() {
+		stats.Running = now
+	}
+	if reason := FailureReason(p); reason != "" && stats.Failed.IsZero() {
+		stats.Failed = now
+		stats.FailureReason = reason
+	}
+	if IsConditionTrue(p, corev1.PodScheduled) && stats.Scheduled.IsZero() {
+		stats.Scheduled = now
+	}
+	if IsConditionTrue(p, corev1.PodInitialized) && stats.Initialized.IsZero() {
+		stats.Initialized = now
+	}
+	if IsConditionTrue(p, corev1.ContainersReady) && stats.ContainersReady.IsZero() {
+		stats.ContainersReady = now
+	}
+	if IsConditionTrue(p, corev1.PodReady) && stats.Ready.IsZero() {
+		stats.Ready = now
+		stats.ContainersStarted = LastContainerStartedTime(p)
+		trans.Ready = true
+	}
+	if p.Status.Phase == corev1.PodSucceeded && stats.Completed.IsZero() {
+		stats.Completed = now
+	}
+	return trans
+}
+
+// ObserveEvent updates the Stats of the pod the given Kubernetes Event is about
+// with the time the event was received at. Events of untracked pods are
+// ignored.
+func (t *Tracker) ObserveEvent(e *corev1.Event, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := t.stats[e.InvolvedObject.Name]
+	if e.InvolvedObject.Kind != "Pod" || stats == nil {
+		return
+	}
+	switch e.Reason {
+	case "Pulling":
+		if stats.PullStarted.IsZero() {
+			stats.PullStarted = now
+		}
+	case "Pulled":
+		// Only count pulls that actually happened, not cached images.
+		if !stats.PullStarted.IsZero() {
+			stats.PullFinished = now
+		}
+	}
+}
+
+// Update calls fn with the Stats of the given pod while holding the lock.
+func (t *Tracker) Update(name string, fn func(*Stats)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := t.stats[name]
+	if stats == nil {
+		stats = &Stats{}
+		t.stats[name] = stats
+	}
+	fn(stats)
+}
+
+// Has returns true if the given pod is tracked.
+func (t *Tracker) Has(name string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, ok := t.stats[name]
+	return ok
+}
+
+// Stats returns a snapshot of the Stats of all observed pods.
+func (t *Tracker) Stats() map[string]*Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := make(map[string]*Stats, len(t.stats))
+	for name, s := range t.stats {
+		copied := *s
+		stats[name] = &copied
+	}
+	return stats
+}
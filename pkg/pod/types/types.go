@@ -1,8 +1,10 @@
 package types
 
 import (
+	"bytes"
 	"embed"
 	"fmt"
+	"os"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -16,6 +18,15 @@ var fs embed.FS
 
 const folder = "manifests"
 
+// AppImageEnv is the environment variable that optionally overrides the image
+// of the test application in applications/basic, which most built-in types
+// run. job.yaml sets it to a build of the application via ko.
+const AppImageEnv = "PODSPEED_APP_IMAGE"
+
+// appImage is the published image of the test application the built-in types
+// run by default.
+const appImage = "docker.io/markusthoemmes/basic-500716b931f14b4a09df1ec4b4c5550d@sha256:06a71c34b05cd9d74fb9aa904ba256b525a7c39df0708b8cbbfcce923ad8af01"
+
 func Names() ([]string, error) {
 	files, err := fs.ReadDir(folder)
 	if err != nil {
@@ -33,13 +44,24 @@ func Names() ([]string, error) {
 }
 
 func GetConstructor(name string) (func(string, string) *corev1.Pod, error) {
-	file, err := fs.Open(filepath.Join(folder, name+".yaml"))
+	content, err := Manifest(name)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open built in template: %w", err)
+		return nil, err
 	}
-	defer file.Close()
+	return template.PodConstructorFromYAML(bytes.NewReader(content))
+}
 
-	return template.PodConstructorFromYAML(file)
+// Manifest returns the YAML template of the given type, with the image of the
+// test application overridden by AppImageEnv if set.
+func Manifest(name string) ([]byte, error) {
+	content, err := fs.ReadFile(filepath.Join(folder, name+".yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read built in template: %w", err)
+	}
+	if image := os.Getenv(AppImageEnv); image != "" {
+		content = bytes.ReplaceAll(content, []byte(appImage), []byte(image))
+	}
+	return content, nil
 }
 
 func fileNameWithoutExtension(fileName string) string {
@@ -0,0 +1,151 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/markusthoemmes/podspeed/pkg/benchmark"
+	"github.com/markusthoemmes/podspeed/pkg/companion"
+	podtemplate "github.com/markusthoemmes/podspeed/pkg/pod/template"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Permission is a set of verbs on a namespaced resource podspeed needs.
+type Permission struct {
+	Group    string
+	Resource string
+	Verbs    []string
+}
+
+// workloadResources are the resources created for each workload, besides bare
+// pods.
+var workloadResources = map[benchmark.Workload]Permission{
+	benchmark.WorkloadDeployment:  {Group: "apps", Resource: "deployments", Verbs: []string{"create", "delete"}},
+	benchmark.WorkloadReplicaSet:  {Group: "apps", Resource: "replicasets", Verbs: []string{"create", "delete"}},
+	benchmark.WorkloadStatefulSet: {Group: "apps", Resource: "statefulsets", Verbs: []string{"create", "delete"}},
+	benchmark.WorkloadJob:         {Group: "batch", Resource: "jobs", Verbs: []string{"create", "delete", "watch"}},
+}
+
+// permissions returns the permissions a run with the given options needs in
+// each of its namespaces.
+func (o Options) permissions() ([]Permission, error) {
+	var perms []Permission
+	add := func(p Permission) {
+		for i := range perms {
+			if perms[i].Group == p.Group && perms[i].Resource == p.Resource {
+				for _, verb := range p.Verbs {
+					if !contains(perms[i].Verbs, verb) {
+						perms[i].Verbs = append(perms[i].Verbs, verb)
+					}
+				}
+				return
+			}
+		}
+		perms = append(perms, Permission{Group: p.Group, Resource: p.Resource, Verbs: append([]string{}, p.Verbs...)})
+	}
+
+	if o.Knative {
+		add(Permission{Group: "serving.knative.dev", Resource: "services", Verbs: []string{"create", "get", "delete"}})
+		add(Permission{Group: "serving.knative.dev", Resource: "revisions", Verbs: []string{"watch"}})
+		add(Permission{Resource: "pods", Verbs: []string{"watch"}})
+	}
+	workloads := o.Workloads
+	if len(workloads) == 0 && !o.Knative {
+		workloads = []benchmark.Workload{benchmark.WorkloadPod}
+	}
+	for _, w := range workloads {
+		if w == benchmark.WorkloadPod {
+			add(Permission{Resource: "pods", Verbs: []string{"create", "delete", "watch"}})
+			continue
+		}
+		p, ok := workloadResources[w]
+		if !ok {
+			return nil, fmt.Errorf("unsupported workload %q", w)
+		}
+		add(p)
+		add(Permission{Resource: "pods", Verbs: []string{"watch", "deletecollection"}})
+	}
+	if o.Endpoints || o.ServiceProbe {
+		add(Permission{Resource: "services", Verbs: []string{"create", "delete"}})
+	}
+	if o.Endpoints {
+		add(Permission{Group: "discovery.k8s.io", Resource: "endpointslices", Verbs: []string{"watch"}})
+	}
+	if o.Events {
+		add(Permission{Resource: "events", Verbs: []string{"watch"}})
+	}
+	if o.Prepull {
+		add(Permission{Group: "apps", Resource: "daemonsets", Verbs: []string{"create", "get", "delete"}})
+	}
+	if o.Companions != nil {
+		for _, scope := range []podtemplate.Scope{podtemplate.ScopeRun, podtemplate.ScopePod} {
+			objs, err := o.Companions(scope, o.Namespace, "podspeed-preflight")
+			if err != nil {
+				return nil, fmt.Errorf("failed to generate companion objects: %w", err)
+			}
+			for _, obj := range objs {
+				resource, err := companion.Resource(obj)
+				if err != nil {
+					return nil, err
+				}
+				add(Permission{Resource: resource, Verbs: []string{"create", "delete"}})
+			}
+		}
+	}
+	return perms, nil
+}
+
+// checkPermissions asks the API server whether the current user has all
+// permissions the run needs in the namespace of the given options.
+func checkPermissions(ctx context.Context, kube kubernetes.Interface, opts Options) (string, error) {
+	perms, err := opts.permissions()
+	if err != nil {
+		return "", err
+	}
+	var missing []string
+	checked := 0
+	for _, p := range perms {
+		for _, verb := range p.Verbs {
+			review, err := kube.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Namespace: opts.Namespace,
+						Verb:      verb,
+						Group:     p.Group,
+						Resource:  p.Resource,
+					},
+				},
+			}, metav1.CreateOptions{})
+			if err != nil {
+				return "", fmt.Errorf("failed to review access: %w", err)
+			}
+			checked++
+			if !review.Status.Allowed {
+				missing = append(missing, verb+" "+p.name())
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("not allowed to %s", strings.Join(missing, ", "))
+	}
+	return fmt.Sprintf("all %d permissions granted", checked), nil
+}
+
+func (p Permission) name() string {
+	if p.Group == "" {
+		return p.Resource
+	}
+	return p.Resource + "." + p.Group
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package preflight
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/markusthoemmes/podspeed/pkg/benchmark"
+	podtemplate "github.com/markusthoemmes/podspeed/pkg/pod/template"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		denied  string
+		want    []string
+		wantErr string
+	}{{
+		name: "bare pods",
+		opts: Options{Namespace: "a"},
+		want: []string{"a: create pods", "a: delete pods", "a: watch pods"},
+	}, {
+		name: "spread across namespaces",
+		opts: Options{Namespaces: []string{"a", "b"}, Pods: 2},
+		want: []string{
+			"a: create pods", "a: delete pods", "a: watch pods",
+			"b: create pods", "b: delete pods", "b: watch pods",
+		},
+	}, {
+		name: "job with events",
+		opts: Options{Namespace: "a", Workloads: []benchmark.Workload{benchmark.WorkloadJob}, Events: true},
+		want: []string{
+			"a: create jobs.batch", "a: delete jobs.batch", "a: deletecollection pods",
+			"a: watch events", "a: watch jobs.batch", "a: watch pods",
+		},
+	}, {
+		name: "knative",
+		opts: Options{Namespace: "a", Knative: true},
+		want: []string{
+			"a: create services.serving.knative.dev", "a: delete services.serving.knative.dev",
+			"a: get services.serving.knative.dev", "a: watch pods", "a: watch revisions.serving.knative.dev",
+		},
+	}, {
+		name: "endpoints, prepull and companions",
+		opts: Options{
+			Namespace: "a",
+			Endpoints: true,
+			Prepull:   true,
+			Companions: func(scope podtemplate.Scope, ns, pod string) ([]runtime.Object, error) {
+				if scope == podtemplate.ScopeRun {
+					return []runtime.Object{&corev1.ConfigMap{}}, nil
+				}
+				return []runtime.Object{&corev1.Service{}}, nil
+			},
+		},
+		want: []string{
+			"a: create configmaps", "a: create daemonsets.apps", "a: create pods", "a: create services",
+			"a: delete configmaps", "a: delete daemonsets.apps", "a: delete pods", "a: delete services",
+			"a: get daemonsets.apps", "a: watch endpointslices.discovery.k8s.io", "a: watch pods",
+		},
+	}, {
+		name:    "denied",
+		opts:    Options{Namespaces: []string{"a", "b"}, Pods: 2},
+		denied:  "b: delete pods",
+		want:    []string{"a: create pods", "a: delete pods", "a: watch pods", "b: create pods", "b: delete pods", "b: watch pods"},
+		wantErr: "b: not allowed to delete pods",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var reviewed []string
+			kube := fake.NewSimpleClientset()
+			kube.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+				attrs := review.Spec.ResourceAttributes
+				name := attrs.Resource
+				if attrs.Group != "" {
+					name += "." + attrs.Group
+				}
+				check := attrs.Namespace + ": " + attrs.Verb + " " + name
+				reviewed = append(reviewed, check)
+				review.Status.Allowed = check != test.denied
+				return true, review, nil
+			})
+
+			var permissions Check
+			for _, check := range Checks(kube, test.opts) {
+				if check.Name == "permissions" {
+					permissions = check
+				}
+			}
+			_, err := permissions.Run(context.Background())
+			if test.wantErr == "" && err != nil {
+				t.Fatalf("Run() = %v", err)
+			}
+			if test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+				t.Fatalf("Run() = %v, want an error containing %q", err, test.wantErr)
+			}
+
+			sort.Strings(reviewed)
+			if !reflect.DeepEqual(reviewed, test.want) {
+				t.Errorf("reviewed = %v, want %v", reviewed, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/markusthoemmes/podspeed/pkg/benchmark"
+	podtemplate "github.com/markusthoemmes/podspeed/pkg/pod/template"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Options describe the run to check the cluster for.
+type Options struct {
+	Namespace string
+	// Namespaces, if set, are checked instead of Namespace, each for its share
+	// of the pods spread across them.
+	Namespaces []string
+	PodFn      func(string, string) *corev1.Pod
+	Pods       int
+
+	// The following describe what the run does, to only check for the
+	// permissions it needs.
+
+	// Workloads are the workloads the pods are created through, bare pods if
+	// unset.
+	Workloads []benchmark.Workload
+	// Knative creates Knative Services instead.
+	Knative bool
+	// Endpoints and ServiceProbe create a Service selecting the pods.
+	Endpoints    bool
+	ServiceProbe bool
+	// Events watches the Kubernetes Events of the pods.
+	Events bool
+	// Prepull creates a DaemonSet pulling the images.
+	Prepull bool
+	// Companions returns the companion objects created along with the pods.
+	Companions func(scope podtemplate.Scope, ns, pod string) ([]runtime.Object, error)
+}
+
+// Check is a single preflight check.
+type Check struct {
+	Name string
+	// Run returns details about the passed check, or why it failed.
+	Run func(context.Context) (string, error)
+}
+
+// Checks returns all checks of whether a run with the given options can
+// succeed in the cluster the client talks to.
+func Checks(kube kubernetes.Interface, opts Options) []Check {
+	shares := opts.shares()
+	// Checks that aren't about a single namespace use the first one.
+	opts.Namespace = shares[0].Namespace
+	return []Check{{
+		Name: "API server",
+		Run: func(context.Context) (string, error) {
+			version, err := kube.Discovery().ServerVersion()
+			if err != nil {
+				return "", fmt.Errorf("failed to reach API server: %w", err)
+			}
+			return "Kubernetes " + version.GitVersion, nil
+		},
+	}, {
+		Name: "namespace",
+		Run: func(ctx context.Context) (string, error) {
+			names := make([]string, 0, len(shares))
+			for _, share := range shares {
+				if _, err := kube.CoreV1().Namespaces().Get(ctx, share.Namespace, metav1.GetOptions{}); err != nil {
+					return "", fmt.Errorf("failed to get namespace %q: %w", share.Namespace, err)
+				}
+				names = append(names, strconv.Quote(share.Namespace))
+			}
+			if len(names) == 1 {
+				return names[0] + " exists", nil
+			}
+			return fmt.Sprintf("all %d namespaces exist", len(names)), nil
+		},
+	}, {
+		Name: "permissions",
+		Run: perNamespace(shares, func(ctx context.Context, opts Options) (string, error) {
+			return checkPermissions(ctx, kube, opts)
+		}),
+	}, {
+		Name: "admission",
+		Run: perNamespace(shares, func(ctx context.Context, opts Options) (string, error) {
+			return checkAdmission(ctx, kube, opts)
+		}),
+	}, {
+		Name: "limit ranges",
+		Run: perNamespace(shares, func(ctx context.Context, opts Options) (string, error) {
+			return checkLimitRanges(ctx, kube, opts)
+		}),
+	}, {
+		Name: "resource quotas",
+		Run: perNamespace(shares, func(ctx context.Context, opts Options) (string, error) {
+			return checkQuotas(ctx, kube, opts)
+		}),
+	}, {
+		Name: "node capacity",
+		Run: func(ctx context.Context) (string, error) {
+			return checkCapacity(ctx, kube, opts)
+		},
+	}}
+}
+
+// checkAdmission creates a pod in dry-run mode, which passes it through all
+// admission, i.e. Pod Security admission and webhooks, without persisting it.
+func checkAdmission(ctx context.Context, kube kubernetes.Interface, opts Options) (string, error) {
+	p := opts.PodFn(opts.Namespace, "podspeed-preflight")
+	if _, err := kube.CoreV1().Pods(opts.Namespace).Create(ctx, p, metav1.CreateOptions{
+		DryRun: []string{metav1.DryRunAll},
+	}); err != nil {
+		return "", fmt.Errorf("pods would be rejected: %w", err)
+	}
+	return "pods would be admitted", nil
+}
+
+// shares splits the options into one per namespace, with the amount of pods
+// created in it when spreading them round-robin.
+func (o Options) shares() []Options {
+	if len(o.Namespaces) == 0 {
+		return []Options{o}
+	}
+	shares := make([]Options, 0, len(o.Namespaces))
+	for i, ns := range o.Namespaces {
+		share := o
+		share.Namespace, share.Namespaces = ns, nil
+		share.Pods = o.Pods / len(o.Namespaces)
+		if i < o.Pods%len(o.Namespaces) {
+			share.Pods++
+		}
+		shares = append(shares, share)
+	}
+	return shares
+}
+
+// perNamespace runs the check for each of the shares, failing if any of them
+// fails. Details that are the same for all namespaces are only reported once.
+func perNamespace(shares []Options, check func(context.Context, Options) (string, error)) func(context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		if len(shares) == 1 {
+			return check(ctx, shares[0])
+		}
+		var first string
+		var details, problems []string
+		same := true
+		for i, opts := range shares {
+			d, err := check(ctx, opts)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", opts.Namespace, err))
+				continue
+			}
+			if i == 0 {
+				first = d
+			}
+			same = same && d == first
+			details = append(details, opts.Namespace+": "+d)
+		}
+		if len(problems) > 0 {
+			return "", errors.New(strings.Join(problems, "; "))
+		}
+		if same {
+			return fmt.Sprintf("%s in all %d namespaces", first, len(shares)), nil
+		}
+		return strings.Join(details, "; "), nil
+	}
+}
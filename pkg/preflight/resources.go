@@ -0,0 +1,356 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// checkLimitRanges checks the pods against the constraints of all LimitRanges
+// in the namespace, after applying their defaults.
+func checkLimitRanges(ctx context.Context, kube kubernetes.Interface, opts Options) (string, error) {
+	ranges, err := kube.CoreV1().LimitRanges(opts.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to list LimitRanges: %w", err)
+	}
+	if len(ranges.Items) == 0 {
+		return "no LimitRanges", nil
+	}
+	p := opts.PodFn(opts.Namespace, "podspeed-preflight")
+	applyDefaults(p, ranges.Items)
+
+	var problems []string
+	for _, lr := range ranges.Items {
+		for _, item := range lr.Spec.Limits {
+			switch item.Type {
+			case corev1.LimitTypeContainer:
+				for _, c := range append(append([]corev1.Container{}, p.Spec.InitContainers...), p.Spec.Containers...) {
+					problems = append(problems, violations(fmt.Sprintf("container %q", c.Name), lr.Name, item, c.Resources.Requests, c.Resources.Limits)...)
+				}
+			case corev1.LimitTypePod:
+				problems = append(problems, violations("pod", lr.Name, item, podResources(p, requests), podResources(p, limits))...)
+			}
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return "", errors.New(strings.Join(problems, "; "))
+	}
+	return fmt.Sprintf("pods are within %d LimitRanges", len(ranges.Items)), nil
+}
+
+// violations returns how the given requests and limits violate the item of
+// a LimitRange, like its admission plugin enforces them.
+func violations(what, lr string, item corev1.LimitRangeItem, requests, limits corev1.ResourceList) []string {
+	var problems []string
+	for name, min := range item.Min {
+		if request, ok := requests[name]; !ok || request.Cmp(min) < 0 {
+			problems = append(problems, fmt.Sprintf("%s: %s request %s is below the minimum %s of LimitRange %q", what, name, format(request, ok), min.String(), lr))
+		}
+	}
+	for name, max := range item.Max {
+		if limit, ok := limits[name]; !ok || limit.Cmp(max) > 0 {
+			problems = append(problems, fmt.Sprintf("%s: %s limit %s is above the maximum %s of LimitRange %q", what, name, format(limit, ok), max.String(), lr))
+		}
+	}
+	for name, ratio := range item.MaxLimitRequestRatio {
+		request, hasRequest := requests[name]
+		limit, hasLimit := limits[name]
+		if !hasRequest || !hasLimit || request.IsZero() {
+			problems = append(problems, fmt.Sprintf("%s: %s needs a request and limit for the maximum limit to request ratio of LimitRange %q", what, name, lr))
+			continue
+		}
+		if float64(limit.MilliValue())/float64(request.MilliValue()) > ratio.AsApproximateFloat64() {
+			problems = append(problems, fmt.Sprintf("%s: %s limit %s is more than %s times the request %s, the maximum of LimitRange %q", what, name, limit.String(), ratio.String(), request.String(), lr))
+		}
+	}
+	return problems
+}
+
+func format(q resource.Quantity, ok bool) string {
+	if !ok {
+		return "(unset)"
+	}
+	return q.String()
+}
+
+// checkQuotas checks whether the ResourceQuotas of the namespace leave room
+// for all pods. Scoped quotas are not evaluated, the dry-run create catches
+// those for a single pod.
+func checkQuotas(ctx context.Context, kube kubernetes.Interface, opts Options) (string, error) {
+	quotas, err := kube.CoreV1().ResourceQuotas(opts.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to list ResourceQuotas: %w", err)
+	}
+	if len(quotas.Items) == 0 {
+		return "no ResourceQuotas", nil
+	}
+	p, err := defaultedPod(ctx, kube, opts)
+	if err != nil {
+		return "", err
+	}
+	usage := quotaUsage(p)
+
+	var problems []string
+	checked := 0
+	for _, quota := range quotas.Items {
+		if len(quota.Spec.Scopes) > 0 || quota.Spec.ScopeSelector != nil {
+			continue
+		}
+		checked++
+		for name, hard := range quota.Spec.Hard {
+			perPod, tracked := usage[name]
+			if !tracked {
+				continue
+			}
+			if perPod == nil {
+				problems = append(problems, fmt.Sprintf("pods must set %s to be admitted by ResourceQuota %q", name, quota.Name))
+				continue
+			}
+			free := hard.DeepCopy()
+			free.Sub(quota.Status.Used[name])
+			needed := times(*perPod, opts.Pods)
+			if needed.Cmp(free) > 0 {
+				problems = append(problems, fmt.Sprintf("%d pods need %s of %s, but ResourceQuota %q only has %s left", opts.Pods, needed.String(), name, quota.Name, free.String()))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return "", errors.New(strings.Join(problems, "; "))
+	}
+	return fmt.Sprintf("%d pods fit into %d ResourceQuotas", opts.Pods, checked), nil
+}
+
+// quotaUsage returns how much of each resource tracked by ResourceQuotas a
+// single pod uses. It's nil for resources the pod doesn't specify.
+func quotaUsage(p *corev1.Pod) map[corev1.ResourceName]*resource.Quantity {
+	usage := map[corev1.ResourceName]*resource.Quantity{
+		corev1.ResourcePods:                     resource.NewQuantity(1, resource.DecimalSI),
+		"count/pods":                            resource.NewQuantity(1, resource.DecimalSI),
+		corev1.ResourceRequestsCPU:              nil,
+		corev1.ResourceRequestsMemory:           nil,
+		corev1.ResourceRequestsEphemeralStorage: nil,
+		corev1.ResourceLimitsCPU:                nil,
+		corev1.ResourceLimitsMemory:             nil,
+		corev1.ResourceLimitsEphemeralStorage:   nil,
+	}
+	for name, q := range podResources(p, requests) {
+		q := q
+		usage[corev1.ResourceName("requests."+name)] = &q
+	}
+	for name, q := range podResources(p, limits) {
+		q := q
+		usage[corev1.ResourceName("limits."+name)] = &q
+	}
+	// Plain resource names are the same as the requests.
+	usage[corev1.ResourceCPU] = usage[corev1.ResourceRequestsCPU]
+	usage[corev1.ResourceMemory] = usage[corev1.ResourceRequestsMemory]
+	usage[corev1.ResourceEphemeralStorage] = usage[corev1.ResourceRequestsEphemeralStorage]
+	return usage
+}
+
+// checkCapacity checks whether the schedulable nodes have room for all pods,
+// given what's requested by the pods already running on them.
+func checkCapacity(ctx context.Context, kube kubernetes.Interface, opts Options) (string, error) {
+	nodes, err := kube.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to list nodes: %w", err)
+	}
+	running, err := kube.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: "status.phase!=Succeeded,status.phase!=Failed",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list pods: %w", err)
+	}
+	p, err := defaultedPod(ctx, kube, opts)
+	if err != nil {
+		return "", err
+	}
+	request := podResources(p, requests)
+
+	used := make(map[string]corev1.ResourceList)
+	count := make(map[string]int64)
+	for i := range running.Items {
+		rp := &running.Items[i]
+		if rp.Spec.NodeName == "" {
+			continue
+		}
+		if used[rp.Spec.NodeName] == nil {
+			used[rp.Spec.NodeName] = corev1.ResourceList{}
+		}
+		add(used[rp.Spec.NodeName], podResources(rp, requests))
+		count[rp.Spec.NodeName]++
+	}
+
+	var fit int64
+	schedulable := 0
+	for _, node := range nodes.Items {
+		if !canSchedule(p, &node) {
+			continue
+		}
+		schedulable++
+		allocatable := node.Status.Allocatable
+		n := allocatable.Pods().Value() - count[node.Name]
+		for name, q := range request {
+			if q.IsZero() {
+				continue
+			}
+			free := allocatable[name].DeepCopy()
+			free.Sub(used[node.Name][name])
+			if m := free.MilliValue() / q.MilliValue(); m < n {
+				n = m
+			}
+		}
+		if n > 0 {
+			fit += n
+		}
+	}
+
+	if schedulable == 0 {
+		return "", fmt.Errorf("none of the %d nodes can schedule the pods, they're cordoned, not ready, tainted or don't match the pods' nodeSelector", len(nodes.Items))
+	}
+	if fit < int64(opts.Pods) {
+		return "", fmt.Errorf("only %d of %d pods fit on the %d schedulable nodes", fit, opts.Pods, schedulable)
+	}
+	return fmt.Sprintf("room for %d pods on %d of %d nodes", fit, schedulable, len(nodes.Items)), nil
+}
+
+// canSchedule returns whether the pod could be scheduled to the node at all,
+// regardless of its free resources. Affinities are not considered.
+func canSchedule(p *corev1.Pod, node *corev1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	ready := false
+	for _, c := range node.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			ready = c.Status == corev1.ConditionTrue
+		}
+	}
+	if !ready {
+		return false
+	}
+	for k, v := range p.Spec.NodeSelector {
+		if node.Labels[k] != v {
+			return false
+		}
+	}
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for j := range p.Spec.Tolerations {
+			if p.Spec.Tolerations[j].ToleratesTaint(taint) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return false
+		}
+	}
+	return true
+}
+
+// defaultedPod returns a pod of the run with the defaults of the namespace's
+// LimitRanges applied, as the quota and scheduler see it.
+func defaultedPod(ctx context.Context, kube kubernetes.Interface, opts Options) (*corev1.Pod, error) {
+	ranges, err := kube.CoreV1().LimitRanges(opts.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list LimitRanges: %w", err)
+	}
+	p := opts.PodFn(opts.Namespace, "podspeed-preflight")
+	applyDefaults(p, ranges.Items)
+	return p, nil
+}
+
+// applyDefaults sets unset requests to the limits, like the API server does,
+// and unset requests and limits to the defaults of the LimitRanges, like
+// their admission plugin does.
+func applyDefaults(p *corev1.Pod, ranges []corev1.LimitRange) {
+	containers := make([]*corev1.Container, 0, len(p.Spec.InitContainers)+len(p.Spec.Containers))
+	for i := range p.Spec.InitContainers {
+		containers = append(containers, &p.Spec.InitContainers[i])
+	}
+	for i := range p.Spec.Containers {
+		containers = append(containers, &p.Spec.Containers[i])
+	}
+
+	for _, c := range containers {
+		if c.Resources.Requests == nil {
+			c.Resources.Requests = corev1.ResourceList{}
+		}
+		if c.Resources.Limits == nil {
+			c.Resources.Limits = corev1.ResourceList{}
+		}
+		for name, q := range c.Resources.Limits {
+			if _, ok := c.Resources.Requests[name]; !ok {
+				c.Resources.Requests[name] = q.DeepCopy()
+			}
+		}
+		for _, lr := range ranges {
+			for _, item := range lr.Spec.Limits {
+				if item.Type != corev1.LimitTypeContainer {
+					continue
+				}
+				for name, q := range item.Default {
+					if _, ok := c.Resources.Limits[name]; !ok {
+						c.Resources.Limits[name] = q.DeepCopy()
+					}
+				}
+				for name, q := range item.DefaultRequest {
+					if _, ok := c.Resources.Requests[name]; !ok {
+						c.Resources.Requests[name] = q.DeepCopy()
+					}
+				}
+			}
+		}
+	}
+}
+
+// resourcesOf selects either the requests or the limits of a container.
+type resourcesOf func(corev1.Container) corev1.ResourceList
+
+func requests(c corev1.Container) corev1.ResourceList { return c.Resources.Requests }
+func limits(c corev1.Container) corev1.ResourceList   { return c.Resources.Limits }
+
+// podResources returns the effective requests or limits of the pod: the sum
+// of its containers or the largest init container, whichever is larger, plus
+// its overhead.
+func podResources(p *corev1.Pod, of resourcesOf) corev1.ResourceList {
+	total := corev1.ResourceList{}
+	for _, c := range p.Spec.Containers {
+		add(total, of(c))
+	}
+	for _, c := range p.Spec.InitContainers {
+		for name, q := range of(c) {
+			if current, ok := total[name]; !ok || q.Cmp(current) > 0 {
+				total[name] = q.DeepCopy()
+			}
+		}
+	}
+	add(total, p.Spec.Overhead)
+	return total
+}
+
+func add(total, list corev1.ResourceList) {
+	for name, q := range list {
+		current := total[name]
+		current.Add(q)
+		total[name] = current
+	}
+}
+
+func times(q resource.Quantity, n int) resource.Quantity {
+	return *resource.NewMilliQuantity(q.MilliValue()*int64(n), q.Format)
+}
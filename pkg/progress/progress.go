@@ -0,0 +1,193 @@
+package progress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+	statistics "github.com/montanaflynn/stats"
+	"golang.org/x/term"
+)
+
+const (
+	// ttyInterval is how often the progress is rendered in place on a TTY.
+	ttyInterval = 250 * time.Millisecond
+	// logInterval is how often a progress line is logged otherwise.
+	logInterval = 10 * time.Second
+	// window is the amount of most recently ready pods the percentiles are
+	// computed over.
+	window = 50
+	// rateWindow is how far back pods becoming ready count towards the
+	// current rate.
+	rateWindow = 10 * time.Second
+)
+
+// Display renders the progress of a run from the Stats recorded by a Tracker.
+// On a TTY, the progress is updated in place. Otherwise, it's logged
+// periodically.
+type Display struct {
+	tracker *pod.Tracker
+	total   int
+	out     io.Writer
+	tty     bool
+	started time.Time
+}
+
+// New creates a Display for the given Tracker, writing to the given file.
+// Total is the amount of pods expected to become ready, zero if unknown.
+func New(tracker *pod.Tracker, total int, out *os.File) *Display {
+	return &Display{
+		tracker: tracker,
+		total:   total,
+		out:     out,
+		tty:     term.IsTerminal(int(out.Fd())),
+	}
+}
+
+// Run renders the progress until the context is done.
+func (d *Display) Run(ctx context.Context) {
+	d.started = time.Now()
+	interval := logInterval
+	if d.tty {
+		interval = ttyInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.render()
+		case <-ctx.Done():
+			if d.tty {
+				// Leave the final state on screen and move past it.
+				d.render()
+				fmt.Fprintln(d.out)
+			}
+			return
+		}
+	}
+}
+
+func (d *Display) render() {
+	line := Summarize(d.tracker.Stats(), d.total, d.started, time.Now()).String()
+	if d.tty {
+		fmt.Fprint(d.out, "\r\033[K"+line)
+		return
+	}
+	log.New(d.out, "", log.LstdFlags).Println(line)
+}
+
+// Summary is a snapshot of the progress of a run.
+type Summary struct {
+	Total     int
+	Created   int
+	Scheduled int
+	Running   int
+	Ready     int
+	Failed    int
+	Deleted   int
+
+	// Rate is the amount of pods that became ready per second, over the last
+	// few seconds.
+	Rate float64
+	// P50 and P95 are the percentiles of the time to ready of the most recently
+	// ready pods.
+	P50 time.Duration
+	P95 time.Duration
+	// ETA is the estimated time until all pods are ready, zero if unknown.
+	ETA time.Duration
+}
+
+// Summarize computes a Summary of the given Stats of a run that started at the
+// given time.
+func Summarize(stats map[string]*pod.Stats, total int, started, now time.Time) Summary {
+	s := Summary{Total: total}
+	ready := make([]*pod.Stats, 0, len(stats))
+	for _, stat := range stats {
+		if !stat.Created.IsZero() {
+			s.Created++
+		}
+		if !stat.Scheduled.IsZero() {
+			s.Scheduled++
+		}
+		if !stat.Running.IsZero() {
+			s.Running++
+		}
+		if !stat.Ready.IsZero() {
+			ready = append(ready, stat)
+		}
+		if !stat.Failed.IsZero() {
+			s.Failed++
+		}
+		if !stat.Deleted.IsZero() {
+			s.Deleted++
+		}
+	}
+	s.Ready = len(ready)
+	if s.Ready == 0 {
+		return s
+	}
+
+	// Pods that became ready in the window, or since the start if that's more
+	// recent.
+	since := now.Add(-rateWindow)
+	if started.After(since) {
+		since = started
+	}
+	if elapsed := now.Sub(since); elapsed > 0 {
+		recent := 0
+		for _, stat := range ready {
+			if stat.Ready.After(since) {
+				recent++
+			}
+		}
+		s.Rate = float64(recent) / elapsed.Seconds()
+	}
+	if total > s.Ready && s.Rate > 0 {
+		s.ETA = time.Duration(float64(total-s.Ready) / s.Rate * float64(time.Second))
+	}
+
+	sort.Slice(ready, func(i, j int) bool {
+		return ready[i].Ready.Before(ready[j].Ready)
+	})
+	if len(ready) > window {
+		ready = ready[len(ready)-window:]
+	}
+	data := make([]float64, 0, len(ready))
+	for _, stat := range ready {
+		data = append(data, float64(stat.TimeToReady()))
+	}
+	p50, _ := statistics.Percentile(data, 50)
+	p95, _ := statistics.Percentile(data, 95)
+	s.P50, s.P95 = time.Duration(p50), time.Duration(p95)
+	return s
+}
+
+func (s Summary) String() string {
+	ready := fmt.Sprintf("ready %d", s.Ready)
+	if s.Total > 0 {
+		ready += fmt.Sprintf("/%d", s.Total)
+	}
+	parts := []string{
+		fmt.Sprintf("created %d", s.Created),
+		fmt.Sprintf("scheduled %d", s.Scheduled),
+		fmt.Sprintf("running %d", s.Running),
+		ready,
+		fmt.Sprintf("failed %d", s.Failed),
+		fmt.Sprintf("deleted %d", s.Deleted),
+		fmt.Sprintf("%.1f pods/s", s.Rate),
+		fmt.Sprintf("p50 %d ms", s.P50/time.Millisecond),
+		fmt.Sprintf("p95 %d ms", s.P95/time.Millisecond),
+	}
+	if s.ETA > 0 {
+		parts = append(parts, "eta "+s.ETA.Round(time.Second).String())
+	}
+	return strings.Join(parts, " | ")
+}
@@ -0,0 +1,109 @@
+package progress
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+)
+
+var started = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// readyAt returns the Stats of pods that were created at the start and became
+// ready after the given durations.
+func readyAt(ready ...time.Duration) map[string]*pod.Stats {
+	stats := make(map[string]*pod.Stats, len(ready))
+	for i, d := range ready {
+		stats[fmt.Sprint(i)] = &pod.Stats{Created: started, Scheduled: started, Running: started, Ready: started.Add(d)}
+	}
+	return stats
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name    string
+		stats   map[string]*pod.Stats
+		total   int
+		elapsed time.Duration
+		want    Summary
+	}{{
+		name:    "nothing ready",
+		stats:   map[string]*pod.Stats{"a": {Created: started}, "b": {Created: started, Scheduled: started}},
+		total:   2,
+		elapsed: time.Second,
+		want:    Summary{Total: 2, Created: 2, Scheduled: 1},
+	}, {
+		name:    "within the first window",
+		stats:   readyAt(time.Second, 2*time.Second),
+		total:   6,
+		elapsed: 4 * time.Second,
+		want: Summary{
+			Total: 6, Created: 2, Scheduled: 2, Running: 2, Ready: 2,
+			Rate: 0.5, P50: time.Second, P95: 1500 * time.Millisecond, ETA: 8 * time.Second,
+		},
+	}, {
+		name: "only recent pods count",
+		// Four pods ready early on, two in the last ten seconds.
+		stats:   readyAt(time.Second, time.Second, time.Second, time.Second, 25*time.Second, 28*time.Second),
+		total:   10,
+		elapsed: 30 * time.Second,
+		want: Summary{
+			Total: 10, Created: 6, Scheduled: 6, Running: 6, Ready: 6,
+			Rate: 0.2, P50: time.Second, P95: 26500 * time.Millisecond, ETA: 20 * time.Second,
+		},
+	}, {
+		name:    "stalled",
+		stats:   readyAt(time.Second),
+		total:   2,
+		elapsed: time.Minute,
+		want: Summary{
+			Total: 2, Created: 1, Scheduled: 1, Running: 1, Ready: 1,
+			P50: time.Second, P95: time.Second,
+		},
+	}, {
+		name: "failed and deleted",
+		stats: map[string]*pod.Stats{
+			"a": {Created: started, Failed: started.Add(time.Second)},
+			"b": {Created: started, Ready: started.Add(time.Second), Deleted: started.Add(2 * time.Second)},
+		},
+		elapsed: 2 * time.Second,
+		want: Summary{
+			Created: 2, Ready: 1, Failed: 1, Deleted: 1,
+			Rate: 0.5, P50: time.Second, P95: time.Second,
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Summarize(test.stats, test.total, started, started.Add(test.elapsed))
+			if got != test.want {
+				t.Errorf("Summarize() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSummaryString(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary Summary
+		want    string
+	}{{
+		name:    "unknown total",
+		summary: Summary{Created: 1, Ready: 1, Rate: 0.5, P50: time.Second, P95: 2 * time.Second},
+		want:    "created 1 | scheduled 0 | running 0 | ready 1 | failed 0 | deleted 0 | 0.5 pods/s | p50 1000 ms | p95 2000 ms",
+	}, {
+		name:    "with eta",
+		summary: Summary{Total: 3, Created: 1, Ready: 1, Rate: 1, ETA: 2 * time.Second},
+		want:    "created 1 | scheduled 0 | running 0 | ready 1/3 | failed 0 | deleted 0 | 1.0 pods/s | p50 0 ms | p95 0 ms | eta 2s",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.summary.String(); got != test.want {
+				t.Errorf("String() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
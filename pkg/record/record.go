@@ -0,0 +1,184 @@
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// Kind is the kind of a recorded entry.
+type Kind string
+
+const (
+	// KindHeader describes the recorded run.
+	KindHeader Kind = "Header"
+	// KindPod is a watch event of a pod.
+	KindPod Kind = "Pod"
+	// KindEvent is a Kubernetes Event about a pod.
+	KindEvent Kind = "Event"
+	// KindStats are the final Stats of a pod.
+	KindStats Kind = "Stats"
+	// KindResult is the outcome of the recorded run.
+	KindResult Kind = "Result"
+)
+
+// Entry is a single line of a recording.
+type Entry struct {
+	// Received is when podspeed received the object.
+	Received time.Time `json:"received"`
+	Kind     Kind      `json:"kind"`
+	// Type is the type of watch events.
+	Type watch.EventType `json:"type,omitempty"`
+	// Name is the name of the pod of Stats entries.
+	Name   string          `json:"name,omitempty"`
+	Object json.RawMessage `json:"object"`
+}
+
+// Recorder writes the objects observed during a run as JSON lines. It's safe
+// for concurrent use.
+type Recorder struct {
+	mu  sync.Mutex
+	w   *bufio.Writer
+	enc *json.Encoder
+	err error
+}
+
+func NewRecorder(w io.Writer) *Recorder {
+	buf := bufio.NewWriter(w)
+	return &Recorder{
+		w:   buf,
+		enc: json.NewEncoder(buf),
+	}
+}
+
+// Header records the description of the run.
+func (r *Recorder) Header(v interface{}) {
+	r.write(Entry{Received: time.Now(), Kind: KindHeader}, v)
+}
+
+// Pod records a watch event of a pod.
+func (r *Recorder) Pod(typ watch.EventType, p *corev1.Pod, now time.Time) {
+	r.write(Entry{Received: now, Kind: KindPod, Type: typ}, p)
+}
+
+// Event records a Kubernetes Event.
+func (r *Recorder) Event(e *corev1.Event, now time.Time) {
+	r.write(Entry{Received: now, Kind: KindEvent}, e)
+}
+
+// Stats records the final Stats of the given pods.
+func (r *Recorder) Stats(stats map[string]*pod.Stats) {
+	now := time.Now()
+	for name, s := range stats {
+		r.write(Entry{Received: now, Kind: KindStats, Name: name}, s)
+	}
+}
+
+// Result records the outcome of the run.
+func (r *Recorder) Result(v interface{}) {
+	r.write(Entry{Received: time.Now(), Kind: KindResult}, v)
+}
+
+// Close flushes the recording and returns the first error that occurred
+// while recording.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.w.Flush(); err != nil && r.err == nil {
+		r.err = fmt.Errorf("failed to flush recording: %w", err)
+	}
+	return r.err
+}
+
+func (r *Recorder) write(e Entry, v interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return
+	}
+	obj, err := json.Marshal(v)
+	if err != nil {
+		r.err = fmt.Errorf("failed to encode %s: %w", e.Kind, err)
+		return
+	}
+	e.Object = obj
+	if err := r.enc.Encode(e); err != nil {
+		r.err = fmt.Errorf("failed to write recording: %w", err)
+	}
+}
+
+// Replay rebuilds the Stats of a recorded run in the given tracker. The header
+// and result are decoded into the given values, if set.
+//
+// Everything derived from watch events and Kubernetes Events is recomputed
+// from them. Timestamps that podspeed gathered otherwise, i.e. from probes or
+// the application, are taken from the recorded Stats.
+func Replay(r io.Reader, tracker *pod.Tracker, header, result interface{}) error {
+	dec := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var e Entry
+		if err := dec.Decode(&e); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to decode entry %d: %w", line, err)
+		}
+
+		var err error
+		switch e.Kind {
+		case KindHeader:
+			if header != nil {
+				err = json.Unmarshal(e.Object, header)
+			}
+		case KindResult:
+			if result != nil {
+				err = json.Unmarshal(e.Object, result)
+			}
+		case KindPod:
+			p := &corev1.Pod{}
+			if err = json.Unmarshal(e.Object, p); err == nil {
+				tracker.Observe(e.Type, p, e.Received)
+			}
+		case KindEvent:
+			ev := &corev1.Event{}
+			if err = json.Unmarshal(e.Object, ev); err == nil {
+				tracker.ObserveEvent(ev, e.Received)
+			}
+		case KindStats:
+			recorded := pod.Stats{}
+			if err = json.Unmarshal(e.Object, &recorded); err == nil {
+				tracker.Update(e.Name, func(s *pod.Stats) {
+					mergeExternal(s, recorded)
+				})
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode %s of entry %d: %w", e.Kind, line, err)
+		}
+	}
+}
+
+// mergeExternal copies the timestamps that are not derived from watch events
+// or Kubernetes Events.
+func mergeExternal(s *pod.Stats, recorded pod.Stats) {
+	s.Requested = recorded.Requested
+	s.CreateStarted = recorded.CreateStarted
+	s.CreateFinished = recorded.CreateFinished
+	s.DryRunStarted = recorded.DryRunStarted
+	s.DryRunFinished = recorded.DryRunFinished
+	s.Probed = recorded.Probed
+	s.EndpointReady = recorded.EndpointReady
+	s.ServiceProbed = recorded.ServiceProbed
+	s.CallbackReceived = recorded.CallbackReceived
+	s.ProcessStarted = recorded.ProcessStarted
+	s.ListenerBound = recorded.ListenerBound
+	s.FirstRequest = recorded.FirstRequest
+}
@@ -0,0 +1,134 @@
+package record
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+var start = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func at(ms int) time.Time {
+	return start.Add(time.Duration(ms) * time.Millisecond)
+}
+
+type testHeader struct {
+	Type string `json:"type"`
+}
+
+type testResult struct {
+	TimeToAvailable time.Duration `json:"timeToAvailable"`
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	p := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pod"}}
+	ready := p.DeepCopy()
+	ready.Spec.NodeName = "node-1"
+	ready.Status.Phase = corev1.PodRunning
+	for _, typ := range []corev1.PodConditionType{corev1.PodScheduled, corev1.PodInitialized, corev1.ContainersReady, corev1.PodReady} {
+		ready.Status.Conditions = append(ready.Status.Conditions, corev1.PodCondition{Type: typ, Status: corev1.ConditionTrue})
+	}
+	pulling := &corev1.Event{
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "pod"},
+		Reason:         "Pulling",
+	}
+
+	var buf bytes.Buffer
+	r := NewRecorder(&buf)
+	r.Header(testHeader{Type: "basic"})
+	r.Pod(watch.Added, p, at(0))
+	r.Event(pulling, at(5))
+	r.Pod(watch.Modified, ready, at(100))
+	// Everything but the externally gathered timestamps is recomputed.
+	r.Stats(map[string]*pod.Stats{"pod": {Created: at(1000), Ready: at(1000), Probed: at(150)}})
+	r.Result(testResult{TimeToAvailable: time.Second})
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	var (
+		header  testHeader
+		result  testResult
+		tracker = pod.NewTracker()
+	)
+	if err := Replay(&buf, tracker, &header, &result); err != nil {
+		t.Fatalf("Replay() = %v", err)
+	}
+	if header.Type != "basic" {
+		t.Errorf("header = %+v, want type basic", header)
+	}
+	if result.TimeToAvailable != time.Second {
+		t.Errorf("result = %+v, want a second to available", result)
+	}
+
+	s := tracker.Stats()["pod"]
+	if s == nil {
+		t.Fatal("no stats of the pod replayed")
+	}
+	for name, got := range map[string]struct{ got, want time.Time }{
+		"created":      {s.Created, at(0)},
+		"pull started": {s.PullStarted, at(5)},
+		"ready":        {s.Ready, at(100)},
+		"probed":       {s.Probed, at(150)},
+	} {
+		if !got.got.Equal(got.want) {
+			t.Errorf("%s = %v, want %v", name, got.got, got.want)
+		}
+	}
+	if s.Node != "node-1" || s.Namespace != "ns" {
+		t.Errorf("node, namespace = %q, %q, want node-1, ns", s.Node, s.Namespace)
+	}
+}
+
+func TestReplayErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		wantErr string
+	}{{
+		name:    "not JSON",
+		in:      "nope",
+		wantErr: "failed to decode entry 1",
+	}, {
+		name:    "invalid pod",
+		in:      `{"kind":"Header","object":{}}` + "\n" + `{"kind":"Pod","type":"ADDED","object":{"metadata":[]}}`,
+		wantErr: "failed to decode Pod of entry 2",
+	}, {
+		name: "unknown kinds are skipped",
+		in:   `{"kind":"Future","object":{}}`,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var header testHeader
+			err := Replay(strings.NewReader(test.in), pod.NewTracker(), &header, nil)
+			if test.wantErr == "" && err != nil {
+				t.Fatalf("Replay() = %v", err)
+			}
+			if test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+				t.Fatalf("Replay() = %v, want an error containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestRecorderError(t *testing.T) {
+	r := NewRecorder(failingWriter{})
+	r.Header(testHeader{})
+	if err := r.Close(); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("Close() = %v, want the write error", err)
+	}
+}
@@ -0,0 +1,483 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+	"github.com/markusthoemmes/podspeed/pkg/trace"
+	statistics "github.com/montanaflynn/stats"
+)
+
+const (
+	chartWidth  = 640
+	chartHeight = 200
+	// margin leaves room for the axis labels of charts.
+	margin = 40
+	// histogramBins is the amount of bins of the histograms.
+	histogramBins = 20
+	// waterfallRow is the height of a single pod in the waterfall.
+	waterfallRow = 14
+	// maxWaterfallPods limits the waterfall to keep the report readable.
+	maxWaterfallPods = 500
+)
+
+// phaseColors are the colors of the phases in the waterfall.
+var phaseColors = map[string]string{
+	"pod startup":     "#d0d7de",
+	"scheduling":      "#4e79a7",
+	"initialization":  "#f28e2b",
+	"image pull":      "#e15759",
+	"container start": "#76b7b2",
+	"readiness":       "#59a14f",
+	"probe":           "#b07aa1",
+}
+
+// Metric is a single metric with its values in milliseconds.
+type Metric struct {
+	Label  string
+	Values []float64
+}
+
+// Comparison shows metrics side by side, i.e. of several clusters.
+type Comparison struct {
+	// Columns name what is compared.
+	Columns []string
+	// Rows hold the values of a metric for each of the columns, in
+	// milliseconds. Columns without values are shown as empty.
+	Rows []ComparisonRow
+}
+
+// ComparisonRow is a single metric of a Comparison.
+type ComparisonRow struct {
+	Label  string
+	Values [][]float64
+}
+
+// Property describes the environment of the results, i.e. the cluster.
+type Property struct {
+	Name  string
+	Value string
+}
+
+// Report is the data a report is generated from.
+type Report struct {
+	// Title is the headline of the report.
+	Title string
+	// Environment is listed at the end of the report.
+	Environment []Property
+	// Comparison is shown ahead of the summary, if set.
+	Comparison *Comparison
+	// Metrics are summarized and charted individually.
+	Metrics []Metric
+	// Pods are the source of the scatter plot, the node and namespace
+	// breakdowns and the waterfall.
+	Pods []Pod
+}
+
+// Pod is a single pod of the report.
+type Pod struct {
+	// Span is the span tree of the pod, as built by trace.PodSpan.
+	Span trace.Span
+	// Stats are the Stats of the pod.
+	Stats pod.Stats
+}
+
+// Write writes the report as a single, self-contained HTML file.
+func Write(w io.Writer, r Report) error {
+	pods := append([]Pod(nil), r.Pods...)
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Span.Start.Before(pods[j].Span.Start)
+	})
+
+	data := struct {
+		Title       string
+		Generated   string
+		Comparison  *comparisonTable
+		Environment []Property
+		Summary     []summaryRow
+		Metrics     []metricCharts
+		Scatter     template.HTML
+		Nodes       []summaryRow
+		Namespaces  []summaryRow
+		Waterfall   template.HTML
+		Legend      []legendEntry
+		Truncated   int
+	}{
+		Title:       r.Title,
+		Generated:   time.Now().Format(time.RFC1123),
+		Comparison:  compare(r.Comparison),
+		Environment: r.Environment,
+		Scatter:     scatter(pods),
+		Nodes:       breakdown(pods, "k8s.node.name"),
+		Waterfall:   waterfall(pods),
+		Legend:      legend(),
+	}
+	// A single namespace is the common case and adds nothing to the summary.
+	if namespaces := breakdown(pods, "k8s.namespace.name"); len(namespaces) > 1 {
+		data.Namespaces = namespaces
+	}
+	if len(pods) > maxWaterfallPods {
+		data.Truncated = len(pods) - maxWaterfallPods
+	}
+	for _, m := range r.Metrics {
+		data.Summary = append(data.Summary, summarize(m.Label, m.Values))
+		data.Metrics = append(data.Metrics, metricCharts{
+			Label:     m.Label,
+			Histogram: histogram(m.Values),
+			CDF:       cdf(m.Values),
+		})
+	}
+	return page.Execute(w, data)
+}
+
+type summaryRow struct {
+	Label                                      string
+	Count                                      int
+	Min, Max, Mean, Median, P25, P75, P95, P99 string
+}
+
+type metricCharts struct {
+	Label     string
+	Histogram template.HTML
+	CDF       template.HTML
+}
+
+type legendEntry struct {
+	Name  string
+	Color template.CSS
+}
+
+func summarize(label string, data []float64) summaryRow {
+	min, _ := statistics.Min(data)
+	max, _ := statistics.Max(data)
+	mean, _ := statistics.Mean(data)
+	median, _ := statistics.Median(data)
+	p25, _ := statistics.Percentile(data, 25)
+	p75, _ := statistics.Percentile(data, 75)
+	p95, _ := statistics.Percentile(data, 95)
+	p99, _ := statistics.Percentile(data, 99)
+	format := func(v float64) string { return fmt.Sprintf("%.0f", v) }
+	return summaryRow{
+		Label: label, Count: len(data),
+		Min: format(min), Max: format(max), Mean: format(mean), Median: format(median),
+		P25: format(p25), P75: format(p75), P95: format(p95), P99: format(p99),
+	}
+}
+
+type comparisonTable struct {
+	Columns []string
+	Rows    []comparisonRow
+}
+
+type comparisonRow struct {
+	Label string
+	Cells []string
+}
+
+// compare shows the median and p95 of each row for each of the columns.
+func compare(c *Comparison) *comparisonTable {
+	if c == nil {
+		return nil
+	}
+	table := &comparisonTable{Columns: c.Columns}
+	for _, row := range c.Rows {
+		cells := make([]string, 0, 2*len(c.Columns))
+		for i := range c.Columns {
+			var values []float64
+			if i < len(row.Values) {
+				values = row.Values[i]
+			}
+			if len(values) == 0 {
+				cells = append(cells, "", "")
+				continue
+			}
+			median, _ := statistics.Median(values)
+			p95, _ := statistics.Percentile(values, 95)
+			cells = append(cells, fmt.Sprintf("%.0f", median), fmt.Sprintf("%.0f", p95))
+		}
+		table.Rows = append(table.Rows, comparisonRow{Label: row.Label, Cells: cells})
+	}
+	return table
+}
+
+// breakdown summarizes the time to ready of the pods per value of the given
+// span attribute, i.e. per node.
+func breakdown(pods []Pod, attribute string) []summaryRow {
+	byValue := make(map[string][]float64)
+	for _, p := range pods {
+		ready := p.Stats.TimeToReady()
+		if ready == pod.Unknown {
+			continue
+		}
+		value := p.Span.Attributes[attribute]
+		byValue[value] = append(byValue[value], millis(ready))
+	}
+	values := make([]string, 0, len(byValue))
+	for value := range byValue {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+
+	rows := make([]summaryRow, 0, len(values))
+	for _, value := range values {
+		rows = append(rows, summarize(value, byValue[value]))
+	}
+	return rows
+}
+
+func histogram(data []float64) template.HTML {
+	if len(data) == 0 {
+		return ""
+	}
+	min, _ := statistics.Min(data)
+	max, _ := statistics.Max(data)
+	width := (max - min) / histogramBins
+	if width == 0 {
+		width = 1
+	}
+	bins := make([]int, histogramBins)
+	highest := 0
+	for _, v := range data {
+		i := int((v - min) / width)
+		if i >= histogramBins {
+			i = histogramBins - 1
+		}
+		bins[i]++
+		if bins[i] > highest {
+			highest = bins[i]
+		}
+	}
+
+	c := newChart(min, min+width*histogramBins, 0, float64(highest))
+	barWidth := float64(chartWidth-2*margin) / histogramBins
+	for i, n := range bins {
+		x := c.x(min + float64(i)*width)
+		y := c.y(float64(n))
+		fmt.Fprintf(&c.b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="#4e79a7"><title>%d pods</title></rect>`,
+			x, y, barWidth-1, float64(chartHeight-margin)-y, n)
+	}
+	return c.finish("ms", "pods")
+}
+
+func cdf(data []float64) template.HTML {
+	if len(data) == 0 {
+		return ""
+	}
+	sorted := append([]float64(nil), data...)
+	sort.Float64s(sorted)
+
+	c := newChart(sorted[0], sorted[len(sorted)-1], 0, 1)
+	points := make([]string, 0, len(sorted))
+	for i, v := range sorted {
+		points = append(points, fmt.Sprintf("%.1f,%.1f", c.x(v), c.y(float64(i+1)/float64(len(sorted)))))
+	}
+	fmt.Fprintf(&c.b, `<polyline points="%s" fill="none" stroke="#e15759" stroke-width="2"/>`, strings.Join(points, " "))
+	return c.finish("ms", "fraction")
+}
+
+// scatter plots the time to ready of each pod against the order they were
+// created in. Pods that never became ready are left out.
+func scatter(pods []Pod) template.HTML {
+	max, ready := 0.0, 0
+	for _, p := range pods {
+		if d := p.Stats.TimeToReady(); d != pod.Unknown {
+			max = math.Max(max, millis(d))
+			ready++
+		}
+	}
+	if ready == 0 {
+		return ""
+	}
+
+	c := newChart(1, float64(len(pods)), 0, max)
+	for i, p := range pods {
+		ready := p.Stats.TimeToReady()
+		if ready == pod.Unknown {
+			continue
+		}
+		fmt.Fprintf(&c.b, `<circle cx="%.1f" cy="%.1f" r="2.5" fill="#4e79a7"><title>%s: %.0f ms</title></circle>`,
+			c.x(float64(i+1)), c.y(millis(ready)), html.EscapeString(p.Span.Attributes["k8s.pod.name"]), millis(ready))
+	}
+	return c.finish("pod", "ms")
+}
+
+// waterfall draws a row per pod with its phases on a shared time axis.
+func waterfall(all []Pod) template.HTML {
+	if len(all) > maxWaterfallPods {
+		all = all[:maxWaterfallPods]
+	}
+	if len(all) == 0 {
+		return ""
+	}
+	pods := make([]trace.Span, 0, len(all))
+	for _, p := range all {
+		pods = append(pods, p.Span)
+	}
+	start, end := pods[0].Start, pods[0].End
+	for _, p := range pods {
+		if p.End.After(end) {
+			end = p.End
+		}
+		for _, child := range p.Children {
+			if child.End.After(end) {
+				end = child.End
+			}
+		}
+	}
+
+	const labelWidth = 200
+	width := float64(chartWidth + labelWidth)
+	scale := (width - labelWidth - 10) / math.Max(1, float64(end.Sub(start)))
+	x := func(t time.Time) float64 {
+		return labelWidth + float64(t.Sub(start))*scale
+	}
+
+	var b strings.Builder
+	height := len(pods)*waterfallRow + 20
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%d" font-size="10">`, width, height)
+	for i, p := range pods {
+		y := float64(i * waterfallRow)
+		fmt.Fprintf(&b, `<text x="0" y="%.1f">%s</text>`, y+10, html.EscapeString(p.Attributes["k8s.pod.name"]))
+		for _, s := range append([]trace.Span{p}, p.Children...) {
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%d" fill="%s"><title>%s: %.0f ms</title></rect>`,
+				x(s.Start), y+1, math.Max(1, x(s.End)-x(s.Start)), waterfallRow-2, phaseColors[s.Name],
+				html.EscapeString(s.Name), millis(s.End.Sub(s.Start)))
+		}
+	}
+	fmt.Fprintf(&b, `<text x="%d" y="%d">0 ms</text><text x="%.0f" y="%d" text-anchor="end">%.0f ms</text>`,
+		labelWidth, height-4, width-10, height-4, millis(end.Sub(start)))
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+func legend() []legendEntry {
+	names := []string{"pod startup", "scheduling", "initialization", "image pull", "container start", "readiness", "probe"}
+	entries := make([]legendEntry, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, legendEntry{Name: name, Color: template.CSS(phaseColors[name])})
+	}
+	return entries
+}
+
+// chart is a chart with linear axes.
+type chart struct {
+	b                      strings.Builder
+	minX, maxX, minY, maxY float64
+}
+
+func newChart(minX, maxX, minY, maxY float64) *chart {
+	if maxX == minX {
+		maxX = minX + 1
+	}
+	if maxY == minY {
+		maxY = minY + 1
+	}
+	c := &chart{minX: minX, maxX: maxX, minY: minY, maxY: maxY}
+	fmt.Fprintf(&c.b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-size="10">`, chartWidth, chartHeight)
+	return c
+}
+
+func (c *chart) x(v float64) float64 {
+	return margin + (v-c.minX)/(c.maxX-c.minX)*float64(chartWidth-2*margin)
+}
+
+func (c *chart) y(v float64) float64 {
+	return float64(chartHeight-margin) - (v-c.minY)/(c.maxY-c.minY)*float64(chartHeight-2*margin)
+}
+
+// finish draws the axes with their bounds and returns the chart.
+func (c *chart) finish(xUnit, yUnit string) template.HTML {
+	bottom, right := chartHeight-margin, chartWidth-margin
+	fmt.Fprintf(&c.b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="black"/>`, margin, bottom, right, bottom)
+	fmt.Fprintf(&c.b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="black"/>`, margin, margin, margin, bottom)
+	fmt.Fprintf(&c.b, `<text x="%d" y="%d">%s</text>`, margin, bottom+14, formatBound(c.minX))
+	fmt.Fprintf(&c.b, `<text x="%d" y="%d" text-anchor="end">%s %s</text>`, right, bottom+14, formatBound(c.maxX), xUnit)
+	fmt.Fprintf(&c.b, `<text x="%d" y="%d" text-anchor="end">%s</text>`, margin-4, bottom, formatBound(c.minY))
+	fmt.Fprintf(&c.b, `<text x="%d" y="%d" text-anchor="end">%s</text>`, margin-4, margin+4, formatBound(c.maxY))
+	fmt.Fprintf(&c.b, `<text x="%d" y="%d">%s</text>`, 0, margin-10, yUnit)
+	c.b.WriteString(`</svg>`)
+	return template.HTML(c.b.String())
+}
+
+func formatBound(v float64) string {
+	if v == math.Trunc(v) {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.2f", v)
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+var page = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #24292f; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #d0d7de; padding: 4px 8px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+.charts { display: flex; flex-wrap: wrap; gap: 1em; }
+td.property { text-align: left; word-break: break-all; }
+.legend span { display: inline-block; width: 12px; height: 12px; margin: 0 4px 0 12px; vertical-align: middle; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Generated at {{.Generated}}. All durations are in ms.</p>
+{{with .Comparison}}
+<h2>Comparison</h2>
+<table>
+<tr><th rowspan="2">metric</th>{{range .Columns}}<th colspan="2">{{.}}</th>{{end}}</tr>
+<tr>{{range .Columns}}<th>median</th><th>p95</th>{{end}}</tr>
+{{range .Rows}}<tr><td>{{.Label}}</td>{{range .Cells}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{end}}
+<h2>Summary</h2>
+<table>
+<tr><th>metric</th><th>count</th><th>min</th><th>max</th><th>mean</th><th>median</th><th>p25</th><th>p75</th><th>p95</th><th>p99</th></tr>
+{{range .Summary}}<tr><td>{{.Label}}</td><td>{{.Count}}</td><td>{{.Min}}</td><td>{{.Max}}</td><td>{{.Mean}}</td><td>{{.Median}}</td><td>{{.P25}}</td><td>{{.P75}}</td><td>{{.P95}}</td><td>{{.P99}}</td></tr>
+{{end}}</table>
+
+{{range .Metrics}}<h2>{{.Label}}</h2>
+<div class="charts"><div><h3>Histogram</h3>{{.Histogram}}</div><div><h3>CDF</h3>{{.CDF}}</div></div>
+{{end}}
+<h2>Time to ready by creation order</h2>
+{{.Scatter}}
+
+<h2>Time to ready by node</h2>
+<table>
+<tr><th>node</th><th>pods</th><th>min</th><th>max</th><th>mean</th><th>median</th><th>p25</th><th>p75</th><th>p95</th><th>p99</th></tr>
+{{range .Nodes}}<tr><td>{{.Label}}</td><td>{{.Count}}</td><td>{{.Min}}</td><td>{{.Max}}</td><td>{{.Mean}}</td><td>{{.Median}}</td><td>{{.P25}}</td><td>{{.P75}}</td><td>{{.P95}}</td><td>{{.P99}}</td></tr>
+{{end}}</table>
+{{if .Namespaces}}
+<h2>Time to ready by namespace</h2>
+<table>
+<tr><th>namespace</th><th>pods</th><th>min</th><th>max</th><th>mean</th><th>median</th><th>p25</th><th>p75</th><th>p95</th><th>p99</th></tr>
+{{range .Namespaces}}<tr><td>{{.Label}}</td><td>{{.Count}}</td><td>{{.Min}}</td><td>{{.Max}}</td><td>{{.Mean}}</td><td>{{.Median}}</td><td>{{.P25}}</td><td>{{.P75}}</td><td>{{.P95}}</td><td>{{.P99}}</td></tr>
+{{end}}</table>
+{{end}}
+
+<h2>Waterfall</h2>
+<p class="legend">{{range .Legend}}<span style="background: {{.Color}}"></span>{{.Name}}{{end}}</p>
+{{if .Truncated}}<p>Only the first pods are shown, {{.Truncated}} more are omitted.</p>{{end}}
+{{.Waterfall}}
+{{if .Environment}}
+<h2>Environment</h2>
+<table>
+{{range .Environment}}<tr><td>{{.Name}}</td><td class="property">{{.Value}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
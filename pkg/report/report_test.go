@@ -0,0 +1,134 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+	"github.com/markusthoemmes/podspeed/pkg/trace"
+)
+
+var created = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// testPod returns a pod on the given node that became ready after the given
+// duration, or never if it's zero. Its span ends a second later regardless.
+func testPod(name, node string, ready time.Duration) Pod {
+	s := pod.Stats{Created: created, Node: node}
+	if ready != 0 {
+		s.Ready = created.Add(ready)
+	}
+	return Pod{
+		Span: trace.Span{
+			Name:  "pod startup",
+			Start: created,
+			End:   created.Add(ready + time.Second),
+			Attributes: map[string]string{
+				"k8s.pod.name":  name,
+				"k8s.node.name": node,
+			},
+		},
+		Stats: s,
+	}
+}
+
+func TestBreakdown(t *testing.T) {
+	tests := []struct {
+		name string
+		pods []Pod
+		want []summaryRow
+	}{{
+		name: "empty",
+		want: []summaryRow{},
+	}, {
+		name: "per node",
+		pods: []Pod{
+			testPod("a", "node-2", 300*time.Millisecond),
+			testPod("b", "node-1", 100*time.Millisecond),
+			testPod("c", "node-1", 200*time.Millisecond),
+		},
+		want: []summaryRow{
+			{Label: "node-1", Count: 2, Min: "100", Max: "200"},
+			{Label: "node-2", Count: 1, Min: "300", Max: "300"},
+		},
+	}, {
+		name: "not ready",
+		pods: []Pod{
+			testPod("a", "node-1", 100*time.Millisecond),
+			testPod("b", "node-1", 0),
+			testPod("c", "node-2", 0),
+		},
+		want: []summaryRow{
+			{Label: "node-1", Count: 1, Min: "100", Max: "100"},
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := breakdown(test.pods, "k8s.node.name")
+			if len(got) != len(test.want) {
+				t.Fatalf("breakdown() = %+v, want %+v", got, test.want)
+			}
+			for i, want := range test.want {
+				if got[i].Label != want.Label || got[i].Count != want.Count || got[i].Min != want.Min || got[i].Max != want.Max {
+					t.Errorf("row %d = %+v, want %+v", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestScatter(t *testing.T) {
+	tests := []struct {
+		name   string
+		pods   []Pod
+		points int
+	}{{
+		name: "empty",
+	}, {
+		name: "none ready",
+		pods: []Pod{testPod("a", "node-1", 0)},
+	}, {
+		name: "some ready",
+		pods: []Pod{
+			testPod("a", "node-1", 100*time.Millisecond),
+			testPod("b", "node-1", 0),
+			testPod("c", "node-1", 200*time.Millisecond),
+		},
+		points: 2,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := string(scatter(test.pods))
+			if n := strings.Count(got, "<circle"); n != test.points {
+				t.Errorf("scatter() has %d points, want %d", n, test.points)
+			}
+			// The time to ready is plotted, not the length of the span.
+			if test.points > 0 && !strings.Contains(got, "a: 100 ms") {
+				t.Errorf("scatter() = %s, want the time to ready of pod a", got)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, Report{
+		Title:   "podspeed: test",
+		Metrics: []Metric{{Label: "Time to ready", Values: []float64{100, 200}}},
+		Pods: []Pod{
+			testPod("a", "node-1", 100*time.Millisecond),
+			testPod("b", "node-1", 200*time.Millisecond),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	for _, want := range []string{"podspeed: test", "Time to ready", "node-1", "<svg"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report doesn't contain %q", want)
+		}
+	}
+}
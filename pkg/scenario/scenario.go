@@ -0,0 +1,303 @@
+package scenario
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/markusthoemmes/podspeed/pkg/benchmark"
+	podtypes "github.com/markusthoemmes/podspeed/pkg/pod/types"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+)
+
+// Scenario describes a benchmark as a sequence of steps, each creating a set
+// of pods. All steps share the same pods, namespace and node targeting.
+type Scenario struct {
+	// Name describes the scenario in the outputs.
+	Name string `json:"name"`
+	// Namespace is the namespace to create the pods in, "default" if unset.
+	Namespace string `json:"namespace,omitempty"`
+	// CreateNamespace creates a fresh namespace to create the pods in instead,
+	// which is deleted after all steps.
+	CreateNamespace bool `json:"createNamespace,omitempty"`
+	// Namespaces creates the given amount of fresh namespaces and spreads the
+	// pods across them instead, which are deleted after all steps.
+	Namespaces int `json:"namespaces,omitempty"`
+	// NamespaceSelector spreads the pods across all existing namespaces
+	// matching the label selector instead.
+	NamespaceSelector string `json:"namespaceSelector,omitempty"`
+	// NamespaceLabels are set on the namespaces created via CreateNamespace or
+	// Namespaces.
+	NamespaceLabels map[string]string `json:"namespaceLabels,omitempty"`
+	// Pod defines the pods to create.
+	Pod Pod `json:"pod"`
+	// Prepull pulls all used images to all nodes before the first step.
+	Prepull bool `json:"prepull,omitempty"`
+	// Nodes restricts the nodes the pods are scheduled to.
+	Nodes Nodes `json:"nodes,omitempty"`
+	// Repetitions is how often all steps are run, 1 if unset.
+	Repetitions int `json:"repetitions,omitempty"`
+	// Steps are run one after the other.
+	Steps []Step `json:"steps"`
+	// Outputs configures where the results go besides stdout.
+	Outputs Outputs `json:"outputs,omitempty"`
+}
+
+// Pod defines the pods to create, either through a built-in type or a
+// template.
+type Pod struct {
+	// Type is one of the built-in pod types.
+	Type string `json:"type,omitempty"`
+	// Template is the path to a YAML template, relative to the scenario file.
+	Template string `json:"template,omitempty"`
+}
+
+// Nodes restricts the nodes the pods are scheduled to.
+type Nodes struct {
+	// Name pins all pods to the given node. It's selected via its hostname
+	// label to still measure scheduling.
+	Name string `json:"name,omitempty"`
+	// Selector is added to the node selector of all pods.
+	Selector map[string]string `json:"selector,omitempty"`
+}
+
+// Step is a single run of the scenario.
+type Step struct {
+	// Name identifies the step in the outputs.
+	Name string `json:"name"`
+	// Pods is the amount of pods to create.
+	Pods int `json:"pods"`
+	// Workload is the kind of object to create the pods through, "pod" if
+	// unset.
+	Workload benchmark.Workload `json:"workload,omitempty"`
+	// SkipDelete keeps the pods after the step.
+	SkipDelete bool `json:"skipDelete,omitempty"`
+	// DryRun submits each pod in dry-run mode before creating it, to measure
+	// the time spent in admission. Only supported for bare pods.
+	DryRun bool `json:"dryRun,omitempty"`
+	// Probe configures what's measured besides the pods' status.
+	Probe Probe `json:"probe,omitempty"`
+}
+
+// Probe configures what's measured besides the pods' status.
+type Probe struct {
+	// IP probes the pods as soon as they have an IP address.
+	IP bool `json:"ip,omitempty"`
+	// Endpoints measures when the pods become ready endpoints of a Service.
+	Endpoints bool `json:"endpoints,omitempty"`
+	// Service probes the pods through a Service, via its ClusterIP if "ip" or
+	// its DNS name if "dns".
+	Service benchmark.ServiceProbe `json:"service,omitempty"`
+	// Timestamps fetches the startup timestamps the test application reports.
+	Timestamps bool `json:"timestamps,omitempty"`
+}
+
+// Outputs configures where the results of a scenario go besides stdout. Paths
+// are relative to the scenario file.
+type Outputs struct {
+	// Details prints detailed timing information for each pod.
+	Details bool `json:"details,omitempty"`
+	// Report is the file to write an HTML report to.
+	Report string `json:"report,omitempty"`
+	// Results is the file to write the results to as JSON.
+	Results string `json:"results,omitempty"`
+	// Trace is the file to write a Chrome trace to.
+	Trace string `json:"trace,omitempty"`
+	// OTLP configures where traces of the pods' startup are exported to.
+	OTLP OTLP `json:"otlp,omitempty"`
+	// Pushgateway is the URL of a Prometheus Pushgateway to push to.
+	Pushgateway string `json:"pushgateway,omitempty"`
+	// RemoteWrite is the URL of a Prometheus remote-write endpoint to push to.
+	RemoteWrite string `json:"remoteWrite,omitempty"`
+	// Labels are added to the pushed results.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// OTLP configures where traces of the pods' startup are exported to.
+type OTLP struct {
+	Endpoint string `json:"endpoint,omitempty"`
+	File     string `json:"file,omitempty"`
+}
+
+// Load reads the scenario at the given path, applies defaults and validates
+// it. Relative paths in the scenario are resolved against its directory.
+func Load(path string) (*Scenario, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+	s := &Scenario{}
+	if err := yaml.UnmarshalStrict(content, s); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario: %w", err)
+	}
+	s.setDefaults()
+	errs, err := s.validate()
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		lines := make([]string, 0, len(errs))
+		for _, e := range errs {
+			lines = append(lines, "  "+e.Error())
+		}
+		return nil, fmt.Errorf("invalid scenario %s:\n%s", path, strings.Join(lines, "\n"))
+	}
+	dir := filepath.Dir(path)
+	for _, p := range []*string{&s.Pod.Template, &s.Outputs.Report, &s.Outputs.Results, &s.Outputs.Trace, &s.Outputs.OTLP.File} {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+	return s, nil
+}
+
+func (s *Scenario) setDefaults() {
+	if s.Namespace == "" && !s.CreateNamespace && !s.Spread() {
+		s.Namespace = "default"
+	}
+	if s.Pod.Type == "" && s.Pod.Template == "" {
+		s.Pod.Type = "basic"
+	}
+	if s.Repetitions == 0 {
+		s.Repetitions = 1
+	}
+	for i := range s.Steps {
+		if s.Steps[i].Workload == "" {
+			s.Steps[i].Workload = benchmark.WorkloadPod
+		}
+	}
+}
+
+// Spread returns whether the pods are spread across several namespaces.
+func (s *Scenario) Spread() bool {
+	return s.Namespaces > 0 || s.NamespaceSelector != ""
+}
+
+func (s *Scenario) validate() (field.ErrorList, error) {
+	var errs field.ErrorList
+	if s.Name == "" {
+		errs = append(errs, field.Required(field.NewPath("name"), ""))
+	}
+
+	if s.CreateNamespace && s.Namespace != "" {
+		errs = append(errs, field.Invalid(field.NewPath("namespace"), s.Namespace, "must not be set along with createNamespace"))
+	}
+	if s.Namespaces < 0 {
+		errs = append(errs, field.Invalid(field.NewPath("namespaces"), s.Namespaces, "must not be negative"))
+	}
+	if s.Spread() {
+		if s.Namespace != "" {
+			errs = append(errs, field.Invalid(field.NewPath("namespace"), s.Namespace, "must not be set along with namespaces or namespaceSelector"))
+		}
+		if s.CreateNamespace {
+			errs = append(errs, field.Invalid(field.NewPath("createNamespace"), s.CreateNamespace, "must not be set along with namespaces or namespaceSelector"))
+		}
+	}
+	if s.Namespaces > 0 && s.NamespaceSelector != "" {
+		errs = append(errs, field.Invalid(field.NewPath("namespaceSelector"), s.NamespaceSelector, "must not be set along with namespaces"))
+	}
+	if s.NamespaceSelector != "" {
+		if _, err := labels.Parse(s.NamespaceSelector); err != nil {
+			errs = append(errs, field.Invalid(field.NewPath("namespaceSelector"), s.NamespaceSelector, err.Error()))
+		}
+	}
+	if len(s.NamespaceLabels) > 0 && !s.CreateNamespace && s.Namespaces == 0 {
+		errs = append(errs, field.Invalid(field.NewPath("namespaceLabels"), s.NamespaceLabels, "requires createNamespace or namespaces"))
+	}
+
+	podPath := field.NewPath("pod")
+	if s.Pod.Type != "" && s.Pod.Template != "" {
+		errs = append(errs, field.Invalid(podPath, s.Pod, "only one of type and template may be set"))
+	}
+	if s.Pod.Template == "-" {
+		errs = append(errs, field.Invalid(podPath.Child("template"), s.Pod.Template, "must be a path"))
+	}
+	if s.Pod.Type != "" {
+		names, err := podtypes.Names()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pod types: %w", err)
+		}
+		if !contains(names, s.Pod.Type) {
+			errs = append(errs, field.NotSupported(podPath.Child("type"), s.Pod.Type, names))
+		}
+	}
+
+	if s.Repetitions < 1 {
+		errs = append(errs, field.Invalid(field.NewPath("repetitions"), s.Repetitions, "must be at least 1"))
+	}
+
+	stepsPath := field.NewPath("steps")
+	if len(s.Steps) == 0 {
+		errs = append(errs, field.Required(stepsPath, "at least one step is required"))
+	}
+	names := make(map[string]bool, len(s.Steps))
+	for i, step := range s.Steps {
+		path := stepsPath.Index(i)
+		if step.Name == "" {
+			errs = append(errs, field.Required(path.Child("name"), ""))
+		} else if names[step.Name] {
+			errs = append(errs, field.Duplicate(path.Child("name"), step.Name))
+		}
+		names[step.Name] = true
+
+		if step.Pods < 1 {
+			errs = append(errs, field.Invalid(path.Child("pods"), step.Pods, "must be at least 1"))
+		}
+		if !step.Workload.Supported() {
+			errs = append(errs, field.NotSupported(path.Child("workload"), step.Workload, benchmark.WorkloadNames()))
+		}
+		if step.DryRun && step.Workload != benchmark.WorkloadPod {
+			errs = append(errs, field.Invalid(path.Child("dryRun"), step.DryRun, "is only supported for the pod workload"))
+		}
+		if s.Spread() && (step.Workload != benchmark.WorkloadPod || step.Probe.Endpoints || step.Probe.Service != "") {
+			errs = append(errs, field.Invalid(path, step.Name, "only bare pods without probe.endpoints or probe.service can be spread across namespaces"))
+		}
+		switch step.Probe.Service {
+		case "", benchmark.ServiceProbeIP, benchmark.ServiceProbeDNS:
+		default:
+			errs = append(errs, field.NotSupported(path.Child("probe", "service"), step.Probe.Service,
+				[]string{string(benchmark.ServiceProbeIP), string(benchmark.ServiceProbeDNS)}))
+		}
+	}
+
+	for name := range s.Outputs.Labels {
+		if name == "" {
+			errs = append(errs, field.Invalid(field.NewPath("outputs", "labels"), name, "label names must not be empty"))
+		}
+	}
+	return errs, nil
+}
+
+// PodFn wraps the given pod constructor to apply the node targeting of the
+// scenario.
+func (n Nodes) PodFn(podFn func(string, string) *corev1.Pod) func(string, string) *corev1.Pod {
+	if n.Name == "" && len(n.Selector) == 0 {
+		return podFn
+	}
+	return func(ns, name string) *corev1.Pod {
+		p := podFn(ns, name)
+		if p.Spec.NodeSelector == nil {
+			p.Spec.NodeSelector = make(map[string]string, len(n.Selector)+1)
+		}
+		if n.Name != "" {
+			p.Spec.NodeSelector[corev1.LabelHostname] = n.Name
+		}
+		for k, v := range n.Selector {
+			p.Spec.NodeSelector[k] = v
+		}
+		return p
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
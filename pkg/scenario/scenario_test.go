@@ -0,0 +1,209 @@
+package scenario
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/markusthoemmes/podspeed/pkg/benchmark"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scenario")
+	if err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "scenario.yaml")
+	content := `
+name: test
+pod:
+  template: pod.yaml
+steps:
+- name: warm
+  pods: 2
+outputs:
+  report: report.html
+  trace: /tmp/trace.json
+`
+	if err := ioutil.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write scenario: %v", err)
+	}
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if s.Namespace != "default" || s.Repetitions != 1 || s.Steps[0].Workload != benchmark.WorkloadPod {
+		t.Errorf("scenario = %+v, want the defaults applied", s)
+	}
+	// Relative paths are resolved against the scenario.
+	if want := filepath.Join(dir, "pod.yaml"); s.Pod.Template != want {
+		t.Errorf("template = %s, want %s", s.Pod.Template, want)
+	}
+	if want := filepath.Join(dir, "report.html"); s.Outputs.Report != want {
+		t.Errorf("report = %s, want %s", s.Outputs.Report, want)
+	}
+	if s.Outputs.Trace != "/tmp/trace.json" {
+		t.Errorf("trace = %s, want /tmp/trace.json", s.Outputs.Trace)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr []string
+	}{{
+		name:    "unknown field",
+		content: "name: test\nstep: []",
+		wantErr: []string{"failed to parse scenario", `unknown field "step"`},
+	}, {
+		name:    "missing name and steps",
+		content: "pod:\n  type: basic",
+		wantErr: []string{"name: Required value", "steps: Required value"},
+	}, {
+		name: "invalid steps",
+		content: `
+name: test
+pod:
+  type: nope
+steps:
+- name: a
+  pods: 0
+  workload: cronjob
+- name: a
+  pods: 1
+  workload: deployment
+  dryRun: true
+  probe:
+    service: http
+`,
+		wantErr: []string{
+			`pod.type: Unsupported value: "nope"`,
+			"steps[0].pods: Invalid value: 0: must be at least 1",
+			`steps[0].workload: Unsupported value: "cronjob"`,
+			`steps[1].name: Duplicate value: "a"`,
+			"steps[1].dryRun: Invalid value: true: is only supported for the pod workload",
+			`steps[1].probe.service: Unsupported value: "http"`,
+		},
+	}, {
+		name: "conflicting namespaces",
+		content: `
+name: test
+namespace: bench
+createNamespace: true
+steps:
+- name: a
+  pods: 1
+`,
+		wantErr: []string{"namespace: Invalid value: \"bench\": must not be set along with createNamespace"},
+	}, {
+		name: "spread",
+		content: `
+name: test
+namespaces: 2
+namespaceSelector: "a in (b"
+steps:
+- name: a
+  pods: 1
+  probe:
+    endpoints: true
+`,
+		wantErr: []string{
+			"namespaceSelector: Invalid value",
+			"must not be set along with namespaces",
+			"only bare pods without probe.endpoints or probe.service can be spread across namespaces",
+		},
+	}, {
+		name: "namespace labels without fresh namespaces",
+		content: `
+name: test
+namespaceLabels:
+  a: b
+steps:
+- name: a
+  pods: 1
+`,
+		wantErr: []string{"namespaceLabels: Invalid value", "requires createNamespace or namespaces"},
+	}, {
+		name: "type and template",
+		content: `
+name: test
+pod:
+  type: basic
+  template: pod.yaml
+steps:
+- name: a
+  pods: 1
+`,
+		wantErr: []string{"only one of type and template may be set"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "scenario")
+			if err != nil {
+				t.Fatalf("failed to create directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "scenario.yaml")
+			if err := ioutil.WriteFile(path, []byte(test.content), 0o644); err != nil {
+				t.Fatalf("failed to write scenario: %v", err)
+			}
+
+			_, err = Load(path)
+			if err == nil {
+				t.Fatal("Load() = nil, want an error")
+			}
+			for _, want := range test.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Load() = %v, want an error containing %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPodFn(t *testing.T) {
+	podFn := func(ns, name string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+			Spec:       corev1.PodSpec{NodeSelector: map[string]string{"existing": "true"}},
+		}
+	}
+
+	tests := []struct {
+		name  string
+		nodes Nodes
+		want  map[string]string
+	}{{
+		name: "untargeted",
+		want: map[string]string{"existing": "true"},
+	}, {
+		name:  "pinned",
+		nodes: Nodes{Name: "node-1"},
+		want:  map[string]string{"existing": "true", corev1.LabelHostname: "node-1"},
+	}, {
+		name:  "selected",
+		nodes: Nodes{Selector: map[string]string{"pool": "fast"}},
+		want:  map[string]string{"existing": "true", "pool": "fast"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := test.nodes.PodFn(podFn)("ns", "pod")
+			if p.Namespace != "ns" || p.Name != "pod" {
+				t.Errorf("pod = %s/%s, want ns/pod", p.Namespace, p.Name)
+			}
+			if !reflect.DeepEqual(p.Spec.NodeSelector, test.want) {
+				t.Errorf("node selector = %v, want %v", p.Spec.NodeSelector, test.want)
+			}
+		})
+	}
+}
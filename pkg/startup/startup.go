@@ -0,0 +1,40 @@
+package startup
+
+import "time"
+
+// Path is the path the test application serves its Timestamps on.
+const Path = "/podspeed/timestamps"
+
+// PodNameHeader is the response header the test application identifies the
+// pod that served a request with.
+const PodNameHeader = "X-Pod-Name"
+
+// PodNameEnv is the environment variable the test application reads the name
+// of its pod from, set via the downward API. The hostname isn't used as the
+// kubelet cuts it to 63 characters.
+const PodNameEnv = "POD_NAME"
+
+// Timestamps are the startup timestamps an application reports about itself.
+type Timestamps struct {
+	// ProcessStarted is when the process started running.
+	ProcessStarted time.Time `json:"processStarted"`
+	// ListenerBound is when the process started listening for requests, after
+	// all of its initialization.
+	ListenerBound time.Time `json:"listenerBound"`
+	// FirstRequest is when the process received its first request, not counting
+	// requests for its Timestamps.
+	FirstRequest time.Time `json:"firstRequest,omitempty"`
+}
+
+// CallbackEnv is the environment variable that carries the URL the test
+// application sends its Report to, if set.
+const CallbackEnv = "PODSPEED_CALLBACK_URL"
+
+// Report is what the test application sends to podspeed's callback endpoint
+// as soon as it listens for requests.
+type Report struct {
+	// Pod is the name of the pod the application runs in.
+	Pod string `json:"pod"`
+
+	Timestamps
+}
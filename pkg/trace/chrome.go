@@ -0,0 +1,126 @@
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// unscheduled is the node of pods that were never scheduled.
+const unscheduled = "<unscheduled>"
+
+// chromeEvent is an event of the Chrome Trace Event format.
+type chromeEvent struct {
+	Name  string            `json:"name"`
+	Phase string            `json:"ph"`
+	PID   int               `json:"pid"`
+	TID   int               `json:"tid"`
+	TS    float64           `json:"ts"`
+	Dur   float64           `json:"dur,omitempty"`
+	Args  map[string]string `json:"args,omitempty"`
+}
+
+// EncodeChrome writes the given span trees in the Chrome Trace Event format,
+// as understood by Perfetto and chrome://tracing. Each node is a process and
+// each pod a thread of it, with a slice per span. Spans of a pod that overlap
+// are moved to further threads of the pod.
+func EncodeChrome(w io.Writer, roots []Span) error {
+	var start time.Time
+	byNode := make(map[string][]Span)
+	for _, root := range roots {
+		if start.IsZero() || root.Start.Before(start) {
+			start = root.Start
+		}
+		node := root.Attributes["k8s.node.name"]
+		if node == "" {
+			node = unscheduled
+		}
+		byNode[node] = append(byNode[node], root)
+	}
+	nodes := make([]string, 0, len(byNode))
+	for node := range byNode {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	micros := func(t time.Time) float64 {
+		return float64(t.Sub(start)) / float64(time.Microsecond)
+	}
+	slice := func(s Span, pid, tid int) chromeEvent {
+		args := s.Attributes
+		if s.Error != "" {
+			args = make(map[string]string, len(s.Attributes)+1)
+			for k, v := range s.Attributes {
+				args[k] = v
+			}
+			args["error"] = s.Error
+		}
+		return chromeEvent{
+			Name:  s.Name,
+			Phase: "X",
+			PID:   pid,
+			TID:   tid,
+			TS:    micros(s.Start),
+			Dur:   micros(s.End) - micros(s.Start),
+			Args:  args,
+		}
+	}
+
+	var events []chromeEvent
+	for i, node := range nodes {
+		pid := i + 1
+		events = append(events, chromeEvent{Name: "process_name", Phase: "M", PID: pid, Args: map[string]string{"name": node}})
+
+		pods := byNode[node]
+		sort.Slice(pods, func(i, j int) bool {
+			return pods[i].Start.Before(pods[j].Start)
+		})
+		tid := 0
+		for _, root := range pods {
+			name := root.Attributes["k8s.pod.name"]
+			tid++
+			events = append(events, threadName(pid, tid, name), slice(root, pid, tid))
+
+			// The first lane holds the pod's root slice and all children that
+			// nest within it. Overlapping children go to further lanes.
+			lanes := []lane{{tid: tid}}
+			for _, child := range root.Children {
+				l := -1
+				for i := range lanes {
+					if i == 0 && child.End.After(root.End) {
+						continue
+					}
+					if !child.Start.Before(lanes[i].end) {
+						l = i
+						break
+					}
+				}
+				if l < 0 {
+					tid++
+					lanes = append(lanes, lane{tid: tid})
+					l = len(lanes) - 1
+					events = append(events, threadName(pid, tid, fmt.Sprintf("%s (%d)", name, len(lanes))))
+				}
+				lanes[l].end = child.End
+				events = append(events, slice(child, pid, lanes[l].tid))
+			}
+		}
+	}
+
+	return json.NewEncoder(w).Encode(map[string]interface{}{
+		"traceEvents":     events,
+		"displayTimeUnit": "ms",
+	})
+}
+
+// lane is a thread of a pod that holds non-overlapping slices.
+type lane struct {
+	tid int
+	end time.Time
+}
+
+func threadName(pid, tid int, name string) chromeEvent {
+	return chromeEvent{Name: "thread_name", Phase: "M", PID: pid, TID: tid, Args: map[string]string{"name": name}}
+}
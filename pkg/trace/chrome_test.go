@@ -0,0 +1,90 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeChrome(t *testing.T) {
+	pod := func(name, node string, start, end int, children ...Span) Span {
+		return Span{
+			Name:       "pod startup",
+			Start:      at(start),
+			End:        at(end),
+			Attributes: map[string]string{"k8s.pod.name": name, "k8s.node.name": node},
+			Children:   children,
+		}
+	}
+	phase := func(name string, start, end int) Span {
+		return Span{Name: name, Start: at(start), End: at(end)}
+	}
+
+	tests := []struct {
+		name  string
+		roots []Span
+		want  []chromeEvent
+	}{{
+		name: "nodes are processes and pods threads",
+		roots: []Span{
+			pod("b", "node-2", 5, 10),
+			pod("a", "node-1", 0, 10, phase("scheduling", 0, 2), phase("readiness", 2, 10)),
+		},
+		want: []chromeEvent{
+			{Name: "process_name", Phase: "M", PID: 1, Args: map[string]string{"name": "node-1"}},
+			{Name: "thread_name", Phase: "M", PID: 1, TID: 1, Args: map[string]string{"name": "a"}},
+			{Name: "pod startup", Phase: "X", PID: 1, TID: 1, TS: 0, Dur: 10000, Args: map[string]string{"k8s.pod.name": "a", "k8s.node.name": "node-1"}},
+			{Name: "scheduling", Phase: "X", PID: 1, TID: 1, TS: 0, Dur: 2000},
+			{Name: "readiness", Phase: "X", PID: 1, TID: 1, TS: 2000, Dur: 8000},
+			{Name: "process_name", Phase: "M", PID: 2, Args: map[string]string{"name": "node-2"}},
+			{Name: "thread_name", Phase: "M", PID: 2, TID: 1, Args: map[string]string{"name": "b"}},
+			{Name: "pod startup", Phase: "X", PID: 2, TID: 1, TS: 5000, Dur: 5000, Args: map[string]string{"k8s.pod.name": "b", "k8s.node.name": "node-2"}},
+		},
+	}, {
+		name:  "overlapping children get further threads",
+		roots: []Span{pod("a", "node-1", 0, 10, phase("image pull", 0, 6), phase("probe", 4, 12))},
+		want: []chromeEvent{
+			{Name: "process_name", Phase: "M", PID: 1, Args: map[string]string{"name": "node-1"}},
+			{Name: "thread_name", Phase: "M", PID: 1, TID: 1, Args: map[string]string{"name": "a"}},
+			{Name: "pod startup", Phase: "X", PID: 1, TID: 1, TS: 0, Dur: 10000, Args: map[string]string{"k8s.pod.name": "a", "k8s.node.name": "node-1"}},
+			{Name: "image pull", Phase: "X", PID: 1, TID: 1, TS: 0, Dur: 6000},
+			{Name: "thread_name", Phase: "M", PID: 1, TID: 2, Args: map[string]string{"name": "a (2)"}},
+			{Name: "probe", Phase: "X", PID: 1, TID: 2, TS: 4000, Dur: 8000},
+		},
+	}, {
+		name: "unscheduled and failed",
+		roots: []Span{func() Span {
+			s := pod("a", "", 0, 3)
+			s.Error = "pod never became ready"
+			return s
+		}()},
+		want: []chromeEvent{
+			{Name: "process_name", Phase: "M", PID: 1, Args: map[string]string{"name": unscheduled}},
+			{Name: "thread_name", Phase: "M", PID: 1, TID: 1, Args: map[string]string{"name": "a"}},
+			{Name: "pod startup", Phase: "X", PID: 1, TID: 1, TS: 0, Dur: 3000, Args: map[string]string{"k8s.pod.name": "a", "k8s.node.name": "", "error": "pod never became ready"}},
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := EncodeChrome(&buf, test.roots); err != nil {
+				t.Fatalf("EncodeChrome() = %v", err)
+			}
+			var got struct {
+				TraceEvents     []chromeEvent `json:"traceEvents"`
+				DisplayTimeUnit string        `json:"displayTimeUnit"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("failed to decode trace: %v", err)
+			}
+			if got.DisplayTimeUnit != "ms" {
+				t.Errorf("displayTimeUnit = %q, want ms", got.DisplayTimeUnit)
+			}
+			if !reflect.DeepEqual(got.TraceEvents, test.want) {
+				t.Errorf("events = %+v\nwant %+v", got.TraceEvents, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+)
+
+// serviceName is the service the spans are reported for.
+const serviceName = "podspeed"
+
+// Span is a span of a pod's startup with its child spans.
+type Span struct {
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]string
+	Children   []Span
+	// Error describes why the span failed, if it did.
+	Error string
+}
+
+// PodSpan builds the span tree of a single pod from its Stats. The root span
+// covers the time from creating the pod until it's ready, or completed for
+// pods that never become ready. Pods that did neither get an error and end
+// with the last thing observed of them. Phases that weren't observed are
+// omitted.
+func PodSpan(ns, name string, s pod.Stats, attributes map[string]string) Span {
+	end := s.Ready
+	if end.IsZero() {
+		end = s.Completed
+	}
+	var errMsg string
+	if end.IsZero() {
+		end = lastObserved(s)
+		errMsg = "pod never became ready"
+		if s.FailureReason != "" {
+			errMsg = "pod failed: " + s.FailureReason
+		}
+	}
+	root := Span{
+		Name:  "pod startup",
+		Start: s.Created,
+		End:   end,
+		Error: errMsg,
+		Attributes: map[string]string{
+			"k8s.namespace.name": ns,
+			"k8s.pod.name":       name,
+			"k8s.node.name":      s.Node,
+		},
+	}
+	for k, v := range attributes {
+		root.Attributes[k] = v
+	}
+
+	phases := []Span{
+		{Name: "scheduling", Start: s.Created, End: s.Scheduled},
+		{Name: "initialization", Start: s.Scheduled, End: s.Initialized},
+		{Name: "image pull", Start: s.PullStarted, End: s.PullFinished},
+		// The container start as reported by the kubelet only has second
+		// precision, so the pod being seen running marks it instead.
+		{Name: "container start", Start: s.Initialized, End: s.Running},
+		{Name: "readiness", Start: s.Running, End: s.Ready},
+		{Name: "probe", Start: s.HasIP, End: s.Probed},
+	}
+	for _, phase := range phases {
+		if phase.Start.IsZero() || phase.End.IsZero() || phase.End.Before(phase.Start) {
+			continue
+		}
+		root.Children = append(root.Children, phase)
+	}
+	return root
+}
+
+// lastObserved returns the last point in time the pod was seen making progress.
+func lastObserved(s pod.Stats) time.Time {
+	last := s.Created
+	for _, t := range []time.Time{
+		s.Scheduled, s.Running, s.Initialized, s.PullStarted, s.PullFinished, s.ContainersStarted,
+		s.ContainersReady, s.HasIP, s.Probed, s.EndpointReady, s.ServiceProbed, s.CallbackReceived, s.Failed,
+	} {
+		if t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
+
+// Encode writes the given span trees as an OTLP ExportTraceServiceRequest in
+// its JSON encoding. Each tree gets its own trace.
+func Encode(w io.Writer, roots []Span) error {
+	var spans []otlpSpan
+	for _, root := range roots {
+		traceID, err := randomID(16)
+		if err != nil {
+			return err
+		}
+		spans, err = appendSpans(spans, traceID, "", root)
+		if err != nil {
+			return err
+		}
+	}
+
+	req := map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": attributes(map[string]string{"service.name": serviceName}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": serviceName},
+				"spans": spans,
+			}},
+		}},
+	}
+	return json.NewEncoder(w).Encode(req)
+}
+
+// Export sends the given span trees to the OTLP/HTTP endpoint, i.e.
+// 'http://collector:4318'.
+func Export(ctx context.Context, client *http.Client, endpoint string, roots []Span) error {
+	var body bytes.Buffer
+	if err := Encode(&body, roots); err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	url := strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send spans: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to send spans, got status code %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// otlpSpan is the JSON encoding of an OTLP span.
+type otlpSpan struct {
+	TraceID           string      `json:"traceId"`
+	SpanID            string      `json:"spanId"`
+	ParentSpanID      string      `json:"parentSpanId,omitempty"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []attribute `json:"attributes,omitempty"`
+	Status            *otlpStatus `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type attribute struct {
+	Key   string            `json:"key"`
+	Value map[string]string `json:"value"`
+}
+
+// spanKindInternal is the OTLP span kind of all spans.
+const spanKindInternal = 1
+
+// statusCodeError is the OTLP status code of failed spans.
+const statusCodeError = 2
+
+func appendSpans(spans []otlpSpan, traceID, parentID string, s Span) ([]otlpSpan, error) {
+	spanID, err := randomID(8)
+	if err != nil {
+		return nil, err
+	}
+	var status *otlpStatus
+	if s.Error != "" {
+		status = &otlpStatus{Code: statusCodeError, Message: s.Error}
+	}
+	spans = append(spans, otlpSpan{
+		TraceID:           traceID,
+		SpanID:            spanID,
+		ParentSpanID:      parentID,
+		Name:              s.Name,
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+		Attributes:        attributes(s.Attributes),
+		Status:            status,
+	})
+	for _, child := range s.Children {
+		spans, err = appendSpans(spans, traceID, spanID, child)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return spans, nil
+}
+
+func attributes(attrs map[string]string) []attribute {
+	keys := make([]string, 0, len(attrs))
+	for k, v := range attrs {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	out := make([]attribute, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, attribute{Key: k, Value: map[string]string{"stringValue": attrs[k]}})
+	}
+	return out
+}
+
+// randomID returns a random hex encoded ID of the given amount of bytes.
+func randomID(n int) (string, error) {
+	id := make([]byte, n)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate ID: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
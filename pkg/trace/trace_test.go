@@ -0,0 +1,182 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markusthoemmes/podspeed/pkg/pod"
+)
+
+var start = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func at(ms int) time.Time {
+	return start.Add(time.Duration(ms) * time.Millisecond)
+}
+
+func TestPodSpan(t *testing.T) {
+	tests := []struct {
+		name     string
+		stats    pod.Stats
+		end      time.Time
+		err      string
+		children []string
+	}{{
+		name: "ready",
+		stats: pod.Stats{
+			Created: at(0), Scheduled: at(10), Initialized: at(20), Running: at(300), Ready: at(400),
+			// Truncated to the second, before the pod was initialized.
+			ContainersStarted: at(0),
+		},
+		end:      at(400),
+		children: []string{"scheduling", "initialization", "container start", "readiness"},
+	}, {
+		name: "pulled and probed",
+		stats: pod.Stats{
+			Created: at(0), Scheduled: at(10), Initialized: at(20), PullStarted: at(30), PullFinished: at(200),
+			Running: at(300), Ready: at(400), HasIP: at(250), Probed: at(350),
+		},
+		end:      at(400),
+		children: []string{"scheduling", "initialization", "image pull", "container start", "readiness", "probe"},
+	}, {
+		name:     "completed",
+		stats:    pod.Stats{Created: at(0), Scheduled: at(10), Initialized: at(20), Running: at(300), Completed: at(500)},
+		end:      at(500),
+		children: []string{"scheduling", "initialization", "container start"},
+	}, {
+		name:     "never ready",
+		stats:    pod.Stats{Created: at(0), Scheduled: at(10)},
+		end:      at(10),
+		err:      "pod never became ready",
+		children: []string{"scheduling"},
+	}, {
+		name:  "failed",
+		stats: pod.Stats{Created: at(0), Scheduled: at(10), Failed: at(50), FailureReason: "ErrImagePull"},
+		end:   at(50),
+		err:   "pod failed: ErrImagePull",
+		// The pod got scheduled before it failed.
+		children: []string{"scheduling"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := PodSpan("ns", "pod", test.stats, map[string]string{"podspeed.step": "a"})
+			if !got.Start.Equal(test.stats.Created) || !got.End.Equal(test.end) {
+				t.Errorf("span = %v - %v, want %v - %v", got.Start, got.End, test.stats.Created, test.end)
+			}
+			if got.Error != test.err {
+				t.Errorf("error = %q, want %q", got.Error, test.err)
+			}
+			wantAttributes := map[string]string{
+				"k8s.namespace.name": "ns",
+				"k8s.pod.name":       "pod",
+				"k8s.node.name":      "",
+				"podspeed.step":      "a",
+			}
+			if !reflect.DeepEqual(got.Attributes, wantAttributes) {
+				t.Errorf("attributes = %v, want %v", got.Attributes, wantAttributes)
+			}
+			var children []string
+			for _, child := range got.Children {
+				children = append(children, child.Name)
+			}
+			if !reflect.DeepEqual(children, test.children) {
+				t.Errorf("children = %v, want %v", children, test.children)
+			}
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		status   int
+		wantPath string
+		wantErr  string
+	}{{
+		name:     "base URL",
+		status:   http.StatusOK,
+		wantPath: "/v1/traces",
+	}, {
+		name:     "full URL",
+		path:     "/v1/traces/",
+		status:   http.StatusOK,
+		wantPath: "/v1/traces",
+	}, {
+		name:     "rejected",
+		status:   http.StatusBadRequest,
+		wantPath: "/v1/traces",
+		wantErr:  "got status code 400: no way",
+	}}
+
+	roots := []Span{{
+		Name:       "pod startup",
+		Start:      at(0),
+		End:        at(400),
+		Error:      "pod never became ready",
+		Attributes: map[string]string{"k8s.pod.name": "pod", "k8s.node.name": ""},
+		Children:   []Span{{Name: "scheduling", Start: at(0), End: at(10)}},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var path string
+			var body []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				body, _ = ioutil.ReadAll(r.Body)
+				if test.status != http.StatusOK {
+					http.Error(w, "no way", test.status)
+				}
+			}))
+			defer server.Close()
+
+			err := Export(context.Background(), server.Client(), server.URL+test.path, roots)
+			if test.wantErr == "" && err != nil {
+				t.Fatalf("Export() = %v", err)
+			}
+			if test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+				t.Fatalf("Export() = %v, want an error containing %q", err, test.wantErr)
+			}
+			if path != test.wantPath {
+				t.Errorf("path = %s, want %s", path, test.wantPath)
+			}
+
+			var req struct {
+				ResourceSpans []struct {
+					ScopeSpans []struct {
+						Spans []otlpSpan `json:"spans"`
+					} `json:"scopeSpans"`
+				} `json:"resourceSpans"`
+			}
+			if err := json.Unmarshal(body, &req); err != nil {
+				t.Fatalf("failed to decode request: %v", err)
+			}
+			spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+			if len(spans) != 2 {
+				t.Fatalf("got %d spans, want 2", len(spans))
+			}
+			root, child := spans[0], spans[1]
+			if child.TraceID != root.TraceID || child.ParentSpanID != root.SpanID || root.ParentSpanID != "" {
+				t.Errorf("spans = %+v, want the child to belong to the root", spans)
+			}
+			if root.Status == nil || root.Status.Code != statusCodeError || root.Status.Message != "pod never became ready" {
+				t.Errorf("status = %+v, want the error", root.Status)
+			}
+			// Empty attributes are left out.
+			if want := []attribute{{Key: "k8s.pod.name", Value: map[string]string{"stringValue": "pod"}}}; !reflect.DeepEqual(root.Attributes, want) {
+				t.Errorf("attributes = %+v, want %+v", root.Attributes, want)
+			}
+			if want := "1609459200400000000"; root.EndTimeUnixNano != want {
+				t.Errorf("end = %s, want %s", root.EndTimeUnixNano, want)
+			}
+		})
+	}
+}